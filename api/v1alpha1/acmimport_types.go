@@ -0,0 +1,140 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DeletionPolicy describes what happens to the ACM certificate when it is no longer managed
+// +kubebuilder:validation:Enum=Delete;Retain
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the certificate from ACM
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain leaves the certificate in ACM
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+const (
+	// ConditionReady is true when the referenced certificate has been imported into ACM
+	ConditionReady = "Ready"
+)
+
+// AcmImportSpec defines the certificate to import and where to import it
+type AcmImportSpec struct {
+	// CertificateRef names a cert-manager Certificate in the same namespace whose Secret is imported.
+	// +optional
+	CertificateRef *corev1.LocalObjectReference `json:"certificateRef,omitempty"`
+
+	// SecretRef names a kubernetes.io/tls Secret in the same namespace to import.
+	// Ignored when CertificateRef is set.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Region is the AWS region to import into. Defaults to the region of the controller.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Account is the AWS account ID to import into. Defaults to the account of the controller.
+	// The controller assumes its configured role in other accounts.
+	// +optional
+	Account string `json:"account,omitempty"`
+
+	// Tags are added to the certificate in ACM.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// DeletionPolicy controls whether the ACM certificate is deleted along with this object.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// AcmImportStatus reports the state of the certificate in ACM
+type AcmImportStatus struct {
+	// CertificateArn is the ARN of the certificate in ACM.
+	// +optional
+	CertificateArn string `json:"certificateArn,omitempty"`
+
+	// Region is the AWS region the certificate was imported into, empty for the region of the controller.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Account is the AWS account ID the certificate was imported into, empty for the account of the
+	// controller.
+	// +optional
+	Account string `json:"account,omitempty"`
+
+	// ImportedRevision is the Certificate revision that was last imported.
+	// +optional
+	ImportedRevision int `json:"importedRevision,omitempty"`
+
+	// Fingerprint is the SHA-256 fingerprint of the leaf certificate that was last imported.
+	// +optional
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// NotAfter is the expiry of the certificate that was last imported.
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// LastSyncTime is when the certificate was last imported.
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// InUseBy lists the AWS resources using the certificate.
+	// +optional
+	InUseBy []string `json:"inUseBy,omitempty"`
+
+	// ObservedGeneration is the generation of the spec that was last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the current state of the import.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Arn",type=string,JSONPath=`.status.certificateArn`
+// +kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.status.importedRevision`
+// +kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.notAfter`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// AcmImport imports a certificate into ACM
+type AcmImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AcmImportSpec   `json:"spec,omitempty"`
+	Status AcmImportStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AcmImportList contains a list of AcmImport
+type AcmImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AcmImport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AcmImport{}, &AcmImportList{})
+}
@@ -0,0 +1,87 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IssuerReference identifies a cert-manager issuer
+type IssuerReference struct {
+	// Name of the issuer.
+	Name string `json:"name"`
+
+	// Kind of the issuer, Issuer or ClusterIssuer. Matches any kind when empty.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Group of the issuer. Matches any group when empty.
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// AcmImportPolicySpec selects the Certificates to import and the defaults to import them with.
+// A Certificate matches when it matches every selector that is set.
+type AcmImportPolicySpec struct {
+	// NamespaceSelector selects the namespaces of matching Certificates. Matches all namespaces when unset.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Selector selects matching Certificates by label. Matches all Certificates when unset.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// IssuerRefs matches Certificates issued by any of the listed issuers. Matches any issuer when empty.
+	// +optional
+	IssuerRefs []IssuerReference `json:"issuerRefs,omitempty"`
+
+	// Region is the AWS region to import into. Defaults to the region of the controller.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Tags are added to the certificates in ACM.
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// DeletionPolicy controls whether the ACM certificate is deleted along with the Certificate.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// AcmImportPolicy marks the Certificates it selects as managed
+type AcmImportPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AcmImportPolicySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AcmImportPolicyList contains a list of AcmImportPolicy
+type AcmImportPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AcmImportPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AcmImportPolicy{}, &AcmImportPolicyList{})
+}
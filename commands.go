@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// commands are the one-shot subcommands, which run against the same reconciler as the manager
+var commands = map[string]func(reconciler *controllers.CertificateReconciler, args []string) int{
+	"sync":   runSync,
+	"list":   runList,
+	"gc":     runGc,
+	"import": runImport,
+	"report": runReport,
+}
+
+// runSync reconciles every Certificate once, reporting paused Certificates as failed
+func runSync(reconciler *controllers.CertificateReconciler, args []string) int {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	flags.Parse(args)
+
+	errs, err := reconciler.SyncAll()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to list certificates: %v\n", err)
+		return 1
+	}
+	certIds := make([]string, 0, len(errs))
+	for certId := range errs {
+		certIds = append(certIds, certId)
+	}
+	sort.Strings(certIds)
+	for _, certId := range certIds {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", certId, errs[certId])
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runList prints the ACM certificate of each cert-id in the cache
+func runList(reconciler *controllers.CertificateReconciler, args []string) int {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	flags.Parse(args)
+
+	if _, err := reconciler.Untagged(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to find untagged certificates: %v\n", err)
+		return 1
+	}
+	certIds := make([]string, 0, len(reconciler.Cache))
+	for certId, entry := range reconciler.Cache {
+		if entry != nil {
+			certIds = append(certIds, certId)
+		}
+	}
+	sort.Strings(certIds)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "CERT-ID\tARN\tREGION\tREVISION\tNOT-AFTER\tUNTAGGED")
+	for _, certId := range certIds {
+		entry := reconciler.Cache[certId]
+		region, revision, notAfter, untagged := entry.Region, "-", "-", "-"
+		if region == "" {
+			region = "-"
+		}
+		if value, ok := entry.Revision(); ok {
+			revision = strconv.Itoa(value)
+		}
+		if entry.NotAfter != nil {
+			notAfter = entry.NotAfter.UTC().Format(time.RFC3339)
+		}
+		if entry.Untagged {
+			untagged = "yes"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", certId, aws.StringValue(entry.Summary.CertificateArn), region, revision, notAfter, untagged)
+	}
+	writer.Flush()
+	return 0
+}
+
+// runGc prints the orphaned ACM certificates, deleting them with --delete, and the untagged ones,
+// reimporting them with --retry
+func runGc(reconciler *controllers.CertificateReconciler, args []string) int {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	deleteOrphans := flags.Bool("delete", false, "Delete the orphaned certificates from ACM.")
+	retryUntagged := flags.Bool("retry", false, "Reimport the untagged certificates to retry tagging them.")
+	flags.Parse(args)
+
+	orphans, err := reconciler.Orphans()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to find orphaned certificates: %v\n", err)
+		return 1
+	}
+	untagged, err := reconciler.Untagged()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to find untagged certificates: %v\n", err)
+		return 1
+	}
+
+	status := 0
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "CERT-ID\tARN\tREASON")
+	for _, orphan := range orphans {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", orphan.CertId, aws.StringValue(orphan.Certificate.Summary.CertificateArn), orphan.Reason)
+		if *deleteOrphans {
+			if err := reconciler.DeleteOrphan(orphan); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", orphan.CertId, err)
+				status = 1
+			}
+		}
+	}
+	orphaned := map[string]bool{}
+	for _, orphan := range orphans {
+		orphaned[orphan.CertId] = true
+	}
+	for _, certId := range untagged {
+		if orphaned[certId] {
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", certId, aws.StringValue(reconciler.Cache[certId].Summary.CertificateArn), "Tags not updated")
+		if *retryUntagged {
+			parts := strings.SplitN(certId, "/", 2)
+			if err := reconciler.Import(parts[0], parts[1]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", certId, err)
+				status = 1
+			}
+		}
+	}
+	writer.Flush()
+	return status
+}
+
+// runImport imports one Certificate even when ACM already holds its current revision
+func runImport(reconciler *controllers.CertificateReconciler, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	namespace := flags.String("namespace", "", "The namespace of the Certificate.")
+	certificate := flags.String("certificate", "", "The name of the Certificate.")
+	flags.Parse(args)
+	if *namespace == "" || *certificate == "" {
+		fmt.Fprintln(os.Stderr, "import requires --namespace and --certificate")
+		flags.Usage()
+		return 2
+	}
+
+	if err := reconciler.Import(*namespace, *certificate); err == controllers.ErrPaused {
+		fmt.Fprintf(os.Stderr, "%s/%s: paused\n", *namespace, *certificate)
+		return 1
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to import %s/%s: %v\n", *namespace, *certificate, err)
+		return 1
+	}
+	return 0
+}
+
+// runReport prints the inventory of every ACM certificate as JSON or CSV
+func runReport(reconciler *controllers.CertificateReconciler, args []string) int {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "json", "The output format, json or csv.")
+	expiringWithin := flags.Duration("expiring-within", controllers.DefaultExpiringWithin,
+		"Report certificates expiring within this duration as expiring.")
+	flags.Parse(args)
+	if *format != "json" && *format != "csv" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		flags.Usage()
+		return 2
+	}
+
+	entries, err := reconciler.Inventory(*expiringWithin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to build inventory: %v\n", err)
+		return 1
+	}
+	if *format == "csv" {
+		err = controllers.WriteInventoryCSV(os.Stdout, entries)
+	} else {
+		err = controllers.WriteInventoryJSON(os.Stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write inventory: %v\n", err)
+		return 1
+	}
+	return 0
+}
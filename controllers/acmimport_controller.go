@@ -0,0 +1,666 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/go-logr/logr"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
+	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AcmImportReconciler reconciles an AcmImport object
+type AcmImportReconciler struct {
+	client.Client
+	APIReader   client.Reader
+	Log         logr.Logger
+	Scheme      *runtime.Scheme
+	AcmServices aws2.IAcmServiceFactory
+	// DomainPolicy restricts the DNS names and issuers each namespace may import, when set
+	DomainPolicy *DomainPolicy
+	Recorder     record.EventRecorder
+	// ClusterId is tagged onto imported certificates, when set
+	ClusterId string
+	// ClaimUntagged takes over certificates without a cluster-id tag despite a ClusterId being set
+	ClaimUntagged bool
+	// MaintenanceWindowOverride is how close to its NotAfter an ACM certificate is reimported regardless
+	// of its maintenance window, DefaultMaintenanceWindowOverride when zero
+	MaintenanceWindowOverride time.Duration
+	// ExpiryThresholds are how long before the ACM copy of an AcmImport expires it is warned about and
+	// requeued, DefaultExpiryThresholds when nil
+	ExpiryThresholds []time.Duration
+	// DryRun only logs and records events for the changes that would be made in ACM
+	DryRun bool
+}
+
+// importSource is the Secret an AcmImport imports and what is known about how it was issued
+type importSource struct {
+	secretName string
+	revision   int
+	issuerKind string
+	issuerName string
+}
+
+// +kubebuilder:rbac:groups=acm.legalzoom.com,resources=acmimports,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=acm.legalzoom.com,resources=acmimports/status,verbs=get;update;patch
+
+var (
+	acmImportIdTag     = "legalzoom.com/cert-importer/acm-import"
+	acmImportFinalizer = "acmimport.legalzoom.com"
+	// secretPollInterval is how often AcmImports referencing a Secret directly are re-checked,
+	// since the controller does not watch Secrets
+	secretPollInterval = time.Hour
+)
+
+// resolveSource returns the Secret referenced by an AcmImport. The issuer of a Secret referenced
+// directly is unknown, since its annotations can't be trusted.
+func (r *AcmImportReconciler) resolveSource(ctx context.Context, acmImport *acmv1alpha1.AcmImport) (*importSource, error) {
+	if acmImport.Spec.CertificateRef != nil {
+		var certificate cmapiv1.Certificate
+		if err := r.Get(ctx, types.NamespacedName{
+			Namespace: acmImport.Namespace,
+			Name:      acmImport.Spec.CertificateRef.Name,
+		}, &certificate); err != nil {
+			return nil, err
+		}
+		source := &importSource{
+			secretName: certificate.Spec.SecretName,
+			issuerKind: issuerKind(certificate.Spec.IssuerRef),
+			issuerName: certificate.Spec.IssuerRef.Name,
+		}
+		if certificate.Status.Revision != nil {
+			source.revision = *certificate.Status.Revision
+		}
+		return source, nil
+	}
+	if acmImport.Spec.SecretRef != nil {
+		return &importSource{secretName: acmImport.Spec.SecretRef.Name}, nil
+	}
+	return nil, fmt.Errorf("one of certificateRef or secretRef must be set")
+}
+
+// importTags returns the tags from the AcmImport's spec followed by the tags identifying it, which are
+// applied last so that the spec cannot replace them
+func importTags(acmImport *acmv1alpha1.AcmImport, revision int, clusterId string) []*acm.Tag {
+	spec := userTags(acmImport.Spec.Tags, "AcmImport "+acmImport.Namespace+"/"+acmImport.Name)
+	tags := mergeTags(nil, spec)
+	tags = mergeTags(tags, map[string]string{
+		acmImportIdTag:         types.NamespacedName{Namespace: acmImport.Namespace, Name: acmImport.Name}.String(),
+		certRevisionAnnotation: strconv.Itoa(revision),
+	})
+	return mergeTags(tags, clusterTags(clusterId))
+}
+
+// serviceFor returns the ACM service of a region and account, which only logs changes in a dry run
+func (r *AcmImportReconciler) serviceFor(region string, account string) (aws2.IAcmService, error) {
+	acmService, err := r.AcmServices.ServiceFor(region, account)
+	if err != nil {
+		return nil, err
+	}
+	if r.DryRun {
+		acmService = &aws2.DryRunService{Service: acmService}
+	}
+	return acmService, nil
+}
+
+// moved reports whether the region or account of an AcmImport changed since its certificate was imported
+func moved(acmImport *acmv1alpha1.AcmImport) bool {
+	return acmImport.Status.CertificateArn != "" &&
+		(acmImport.Status.Region != acmImport.Spec.Region || acmImport.Status.Account != acmImport.Spec.Account)
+}
+
+// deletePrevious deletes, or with the Retain deletion policy keeps, the certificate an AcmImport imported
+// before its region or account changed, using the service of the region and account it was imported into
+func (r *AcmImportReconciler) deletePrevious(acmImport *acmv1alpha1.AcmImport, importedService aws2.IAcmService) {
+	previousArn := acmImport.Status.CertificateArn
+	if acmImport.Spec.DeletionPolicy == acmv1alpha1.DeletionPolicyRetain {
+		if r.DryRun {
+			recordEvent(r.Recorder, acmImport, v1.EventTypeNormal, "DryRun",
+				"Would retain previous certificate %s in ACM", previousArn)
+		} else {
+			recordEvent(r.Recorder, acmImport, v1.EventTypeNormal, "Retained",
+				"Retained previous certificate %s in ACM", previousArn)
+		}
+		return
+	}
+	conflict, err := r.ownershipConflict(acmImport, importedService, previousArn)
+	if err != nil {
+		zap.S().Errorw("Failed to list tags of previous certificate in ACM",
+			"acmImport", acmImportId(acmImport),
+			"arn", previousArn,
+			"class", aws2.Classify(err),
+			"error", err,
+		)
+		recordEvent(r.Recorder, acmImport, v1.EventTypeWarning, "DeleteFailed",
+			"Failed to delete previous certificate %s from ACM: %s: %v", previousArn, aws2.ErrorCode(err), err)
+		return
+	}
+	if conflict != nil {
+		recordEvent(r.Recorder, acmImport, v1.EventTypeWarning, "OwnershipConflict",
+			"Retained previous certificate %s in ACM: %v", previousArn, conflict)
+		return
+	}
+	_, err = importedService.DeleteCertificate(&acm.DeleteCertificateInput{
+		CertificateArn: aws.String(previousArn),
+	})
+	if _, ok := err.(*acm.ResourceNotFoundException); err != nil && !ok {
+		zap.S().Errorw("Failed to delete previous certificate in ACM",
+			"acmImport", types.NamespacedName{Namespace: acmImport.Namespace, Name: acmImport.Name}.String(),
+			"arn", previousArn,
+			"class", aws2.Classify(err),
+			"error", err,
+		)
+		recordEvent(r.Recorder, acmImport, v1.EventTypeWarning, "DeleteFailed",
+			"Failed to delete previous certificate %s from ACM: %s: %v", previousArn, aws2.ErrorCode(err), err)
+		return
+	}
+	if r.DryRun {
+		recordEvent(r.Recorder, acmImport, v1.EventTypeNormal, "DryRun",
+			"Would delete previous certificate %s from ACM", previousArn)
+		return
+	}
+	recordEvent(r.Recorder, acmImport, v1.EventTypeNormal, "Deleted",
+		"Deleted previous certificate %s from ACM", previousArn)
+	deletesTotal.WithLabelValues("AcmImport").Inc()
+}
+
+func (r *AcmImportReconciler) setReadyCondition(acmImport *acmv1alpha1.AcmImport, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&acmImport.Status.Conditions, metav1.Condition{
+		Type:               acmv1alpha1.ConditionReady,
+		Status:             status,
+		ObservedGeneration: acmImport.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// acmImportKey is the key of an AcmImport in the state kept for Certificates by cert-id, such as the
+// skip reasons and expiry warnings, which cannot collide with a cert-id
+func acmImportKey(name types.NamespacedName) string {
+	return "AcmImport/" + name.String()
+}
+
+// checkAcmImportOwnership returns an error when an ACM certificate with the given tags was not imported
+// for the AcmImport in this cluster. When migrating, certificates imported for another AcmImport in the
+// same namespace, and certificates that were released or never imported by the controller, are
+// accepted too, but never those of a Certificate or another cluster.
+func checkAcmImportOwnership(acmImport *acmv1alpha1.AcmImport, clusterId string, claimUntagged bool, certificateArn string, tags []*acm.Tag, migrating bool) error {
+	if !clusterMatches(clusterId, claimUntagged, tags) {
+		if owner, ok := tagValue(tags, clusterIdTag); ok {
+			return fmt.Errorf("certificate %s belongs to cluster %s", certificateArn, owner)
+		}
+		return fmt.Errorf("certificate %s has no %s tag", certificateArn, clusterIdTag)
+	}
+	if owner, ok := tagValue(tags, acmImportIdTag); ok {
+		if owner == acmImportId(acmImport) || (migrating && strings.HasPrefix(owner, acmImport.Namespace+"/")) {
+			return nil
+		}
+		return fmt.Errorf("certificate %s belongs to AcmImport %s", certificateArn, owner)
+	}
+	if owner, ok := tagValue(tags, certIdAnnotation); ok {
+		return fmt.Errorf("certificate %s belongs to Certificate %s", certificateArn, owner)
+	}
+	if migrating {
+		return nil
+	}
+	return fmt.Errorf("certificate %s has no %s tag", certificateArn, acmImportIdTag)
+}
+
+// ownershipConflict returns an error describing why the ACM certificate with the given ARN may not be
+// reimported into or deleted by the AcmImport, or the error listing its tags failed with. A certificate
+// that is already gone is no conflict.
+func (r *AcmImportReconciler) ownershipConflict(acmImport *acmv1alpha1.AcmImport, acmService aws2.IAcmService, certificateArn string) (error, error) {
+	output, err := acmService.ListTagsForCertificate(&acm.ListTagsForCertificateInput{CertificateArn: aws.String(certificateArn)})
+	if _, ok := err.(*acm.ResourceNotFoundException); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkAcmImportOwnership(acmImport, r.ClusterId, r.ClaimUntagged, certificateArn, output.Tags, false), nil
+}
+
+// migratedCertificate checks that the AcmImport may take over the ACM certificate its migrate-from
+// annotation names by ARN. A certificate of another AcmImport is only taken over once that AcmImport is
+// gone, so that the two do not reimport into it in turn.
+func (r *AcmImportReconciler) migratedCertificate(ctx context.Context, acmImport *acmv1alpha1.AcmImport, acmService aws2.IAcmService) (string, error) {
+	migrateFrom := acmImport.Annotations[migrateFromAnnotation]
+	if !strings.HasPrefix(migrateFrom, "arn:") {
+		return "", fmt.Errorf("AcmImports can only migrate from an ARN, not %s", migrateFrom)
+	}
+	output, err := acmService.ListTagsForCertificate(&acm.ListTagsForCertificateInput{CertificateArn: aws.String(migrateFrom)})
+	if err != nil {
+		return "", err
+	}
+	if err := checkAcmImportOwnership(acmImport, r.ClusterId, r.ClaimUntagged, migrateFrom, output.Tags, true); err != nil {
+		return "", err
+	}
+	if owner, ok := tagValue(output.Tags, acmImportIdTag); ok && owner != acmImportId(acmImport) {
+		parts := strings.SplitN(owner, "/", 2)
+		var previous acmv1alpha1.AcmImport
+		err := r.Get(ctx, types.NamespacedName{Namespace: parts[0], Name: parts[len(parts)-1]}, &previous)
+		if err == nil {
+			return "", fmt.Errorf("certificate %s still belongs to AcmImport %s; delete it with deletionPolicy Retain first", migrateFrom, owner)
+		}
+		if !apierrors.IsNotFound(err) {
+			return "", err
+		}
+	}
+	return migrateFrom, nil
+}
+
+// acmImportId returns the namespace/name an AcmImport is tagged with
+func acmImportId(acmImport *acmv1alpha1.AcmImport) string {
+	return types.NamespacedName{Namespace: acmImport.Namespace, Name: acmImport.Name}.String()
+}
+
+// checkExpiry warns when the certificate the AcmImport imported crosses an expiry threshold, and whether
+// its Secret, whose leaf is given, holds a renewal that was never imported. The returned Result requeues
+// the AcmImport when the next threshold is crossed.
+func (r *AcmImportReconciler) checkExpiry(acmImport *acmv1alpha1.AcmImport, secretName string, leaf *x509.Certificate, now time.Time) ctrl.Result {
+	key := "AcmImport/" + acmImportId(acmImport)
+	if acmImport.Status.NotAfter == nil {
+		forgetExpiry(key)
+		return ctrl.Result{}
+	}
+	secretLeaf := func() (*x509.Certificate, error) {
+		return leaf, nil
+	}
+	return warnExpiry(r.Recorder, acmImport, key, acmImport.Status.NotAfter.Time, secretLeaf, secretName,
+		sortedExpiryThresholds(r.ExpiryThresholds), now)
+}
+
+// forgetAcmImport removes the state kept for an AcmImport that is gone
+func forgetAcmImport(key string) {
+	setPaused(key, false)
+	forgetExpiry(key)
+	setSkipReason(key, "")
+}
+
+func (r *AcmImportReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	key := acmImportKey(req.NamespacedName)
+
+	var acmImport acmv1alpha1.AcmImport
+	if err := r.Get(ctx, req.NamespacedName, &acmImport); err != nil {
+		if apierrors.IsNotFound(err) {
+			forgetAcmImport(key)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Paused AcmImports keep their finalizer and their ACM certificate as it is, like paused Certificates
+	paused := acmImport.Annotations[pausedAnnotation] == "true"
+	if setPaused(key, paused) && paused {
+		zap.S().Info("Syncing is paused for AcmImport ", req.NamespacedName.String())
+		recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Paused",
+			"Syncing with ACM is paused; imports and deletes are skipped")
+	}
+	if paused {
+		return ctrl.Result{}, nil
+	}
+
+	acmService, err := r.serviceFor(acmImport.Spec.Region, acmImport.Spec.Account)
+	if err != nil {
+		r.setReadyCondition(&acmImport, metav1.ConditionFalse, "InvalidSpec", err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, &acmImport)
+	}
+	// The certificate imported before the region or account changed is deleted through the service it
+	// was imported with
+	importedService := acmService
+	if moved(&acmImport) {
+		importedService, err = r.serviceFor(acmImport.Status.Region, acmImport.Status.Account)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !acmImport.ObjectMeta.DeletionTimestamp.IsZero() {
+		if !contains(acmImport.ObjectMeta.Finalizers, acmImportFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if acmImport.Status.CertificateArn != "" && acmImport.Spec.DeletionPolicy != acmv1alpha1.DeletionPolicyRetain {
+			conflict, err := r.ownershipConflict(&acmImport, importedService, acmImport.Status.CertificateArn)
+			if err != nil {
+				zap.S().Errorw("Failed to list tags of certificate in ACM",
+					"acmImport", req.NamespacedName.String(),
+					"arn", acmImport.Status.CertificateArn,
+					"class", aws2.Classify(err),
+					"error", err,
+				)
+				return requeueForError(err)
+			}
+			if conflict != nil {
+				zap.S().Warnw("Retaining certificate owned by another object",
+					"acmImport", req.NamespacedName.String(),
+					"arn", acmImport.Status.CertificateArn,
+					"reason", conflict.Error(),
+				)
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "OwnershipConflict",
+					"Retained certificate %s in ACM: %v", acmImport.Status.CertificateArn, conflict)
+			} else {
+				zap.S().Infow("Deleting certificate in ACM",
+					"acmImport", req.NamespacedName.String(),
+					"arn", acmImport.Status.CertificateArn,
+				)
+				_, err := importedService.DeleteCertificate(&acm.DeleteCertificateInput{
+					CertificateArn: aws.String(acmImport.Status.CertificateArn),
+				})
+				if _, ok := err.(*acm.ResourceNotFoundException); err != nil && !ok {
+					zap.S().Errorw("Failed to delete certificate in ACM",
+						"acmImport", req.NamespacedName.String(),
+						"arn", acmImport.Status.CertificateArn,
+						"class", aws2.Classify(err),
+						"error", err,
+					)
+					recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "DeleteFailed",
+						"Failed to delete certificate %s from ACM: %s: %v", acmImport.Status.CertificateArn, aws2.ErrorCode(err), err)
+					return requeueForError(err)
+				}
+				if r.DryRun {
+					recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "DryRun",
+						"Would delete certificate %s from ACM", acmImport.Status.CertificateArn)
+				} else {
+					recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Deleted",
+						"Deleted certificate %s from ACM", acmImport.Status.CertificateArn)
+					deletesTotal.WithLabelValues("AcmImport").Inc()
+				}
+			}
+		}
+		setNotAfter("AcmImport", acmImport.Namespace, acmImport.Name, nil)
+		forgetAcmImport(key)
+		acmImport.ObjectMeta.Finalizers = removeString(acmImport.ObjectMeta.Finalizers, acmImportFinalizer)
+		return ctrl.Result{}, r.Update(ctx, &acmImport)
+	}
+
+	if !contains(acmImport.ObjectMeta.Finalizers, acmImportFinalizer) {
+		acmImport.ObjectMeta.Finalizers = append(acmImport.ObjectMeta.Finalizers, acmImportFinalizer)
+		if err := r.Update(ctx, &acmImport); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	result := ctrl.Result{}
+	if acmImport.Spec.CertificateRef == nil {
+		result.RequeueAfter = secretPollInterval
+	}
+
+	importSource, err := r.resolveSource(ctx, &acmImport)
+	if err != nil {
+		r.setReadyCondition(&acmImport, metav1.ConditionFalse, "CertificateNotFound", err.Error())
+		return result, r.Status().Update(ctx, &acmImport)
+	}
+
+	var secret v1.Secret
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: acmImport.Namespace, Name: importSource.secretName}, &secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		r.setReadyCondition(&acmImport, metav1.ConditionFalse, "SecretNotFound", err.Error())
+		return result, r.Status().Update(ctx, &acmImport)
+	}
+
+	certificateData := parseCertificateSecret(&secret)
+	leaf, err := certificateData.Leaf()
+	if err != nil {
+		r.setReadyCondition(&acmImport, metav1.ConditionFalse, "InvalidCertificate", err.Error())
+		return result, r.Status().Update(ctx, &acmImport)
+	}
+	fingerprint := Fingerprint(leaf)
+	revision := importSource.revision
+
+	if r.DomainPolicy != nil {
+		if err := r.DomainPolicy.Allows(acmImport.Namespace, importSource.issuerKind, importSource.issuerName, leaf); err != nil {
+			zap.S().Warnw("Refusing to import certificate",
+				"acmImport", req.NamespacedName.String(),
+				"reason", err.Error(),
+			)
+			recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "ImportRefused", "%v", err)
+			importsRefused.WithLabelValues(acmImport.Namespace).Inc()
+			r.setReadyCondition(&acmImport, metav1.ConditionFalse, "ImportRefused", err.Error())
+			return result, r.Status().Update(ctx, &acmImport)
+		}
+	}
+
+	var lastSync *time.Time
+	if acmImport.Status.LastSyncTime != nil {
+		lastSync = &acmImport.Status.LastSyncTime.Time
+	}
+	requested, dueIn := reimportRequestedSince(acmImport.Annotations, lastSync, time.Now())
+	if dueIn > 0 {
+		result = requeueSooner(result, dueIn)
+	}
+
+	if acmImport.Status.CertificateArn == "" ||
+		acmImport.Status.Fingerprint != fingerprint ||
+		acmImport.Status.ImportedRevision != revision ||
+		acmImport.Status.ObservedGeneration != acmImport.Generation ||
+		requested {
+		// After the region or account changed the certificate is imported anew rather than reimported
+		// into an ARN the new service doesn't know
+		var certificateArn *string
+		migratingFrom := ""
+		if acmImport.Status.CertificateArn != "" && !moved(&acmImport) {
+			certificateArn = aws.String(acmImport.Status.CertificateArn)
+			conflict, err := r.ownershipConflict(&acmImport, acmService, *certificateArn)
+			if err != nil {
+				return r.importFailed(&acmImport, "ImportFailed", err)
+			}
+			if conflict != nil {
+				zap.S().Warnw("Refusing to reimport certificate owned by another object",
+					"acmImport", req.NamespacedName.String(),
+					"arn", *certificateArn,
+					"reason", conflict.Error(),
+				)
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "OwnershipConflict", "%v", conflict)
+				r.setReadyCondition(&acmImport, metav1.ConditionFalse, "OwnershipConflict", conflict.Error())
+				return result, r.Status().Update(ctx, &acmImport)
+			}
+		} else if acmImport.Status.CertificateArn == "" && acmImport.Annotations[migrateFromAnnotation] != "" {
+			migratingFrom, err = r.migratedCertificate(ctx, &acmImport, acmService)
+			if err != nil {
+				zap.S().Errorw("Failed to migrate certificate",
+					"acmImport", req.NamespacedName.String(),
+					"from", acmImport.Annotations[migrateFromAnnotation],
+					"error", err,
+				)
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "MigrationFailed",
+					"Failed to migrate from %s: %v", acmImport.Annotations[migrateFromAnnotation], err)
+				return r.importFailed(&acmImport, "MigrationFailed", err)
+			}
+			certificateArn = aws.String(migratingFrom)
+		}
+
+		if certificateArn != nil && migratingFrom == "" {
+			window, err := objectMaintenanceWindow(r.Client, &acmImport.ObjectMeta)
+			if err != nil {
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "InvalidMaintenanceWindow", "%v", err)
+				r.setReadyCondition(&acmImport, metav1.ConditionFalse, "InvalidMaintenanceWindow", err.Error())
+				return result, r.Status().Update(ctx, &acmImport)
+			}
+			deferFor, err := deferReimport(window, r.MaintenanceWindowOverride, acmService, certificateArn, time.Now())
+			if err != nil {
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "DescribeFailed",
+					"Failed to describe certificate %s: %s: %v", *certificateArn, aws2.ErrorCode(err), err)
+				return r.importFailed(&acmImport, "DescribeFailed", err)
+			}
+			if deferFor > 0 {
+				zap.S().Infow("Deferring reimport until the next maintenance window",
+					"acmImport", req.NamespacedName.String(),
+					"arn", *certificateArn,
+					"after", deferFor.String(),
+				)
+				message := fmt.Sprintf("Deferring reimport into %s until the next maintenance window at %s",
+					*certificateArn, time.Now().Add(deferFor).UTC().Format(time.RFC3339))
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Deferred", "%s", message)
+				r.setReadyCondition(&acmImport, metav1.ConditionFalse, "Deferred", message)
+				result = requeueSooner(result, deferFor)
+				if expiry := r.checkExpiry(&acmImport, importSource.secretName, leaf, time.Now()); expiry.RequeueAfter > 0 {
+					result = requeueSooner(result, expiry.RequeueAfter)
+				}
+				return result, r.Status().Update(ctx, &acmImport)
+			}
+		}
+
+		if waitFor := notYetValidFor(leaf, time.Now()); waitFor > 0 {
+			zap.S().Infow("Delaying import of certificate that is not yet valid",
+				"acmImport", req.NamespacedName.String(),
+				"notBefore", leaf.NotBefore.UTC().Format(time.RFC3339),
+			)
+			message := fmt.Sprintf("Delaying import until the certificate is valid at %s", leaf.NotBefore.UTC().Format(time.RFC3339))
+			recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "NotYetValid", "%s", message)
+			r.setReadyCondition(&acmImport, metav1.ConditionFalse, "NotYetValid", message)
+			result = requeueSooner(result, waitFor)
+			if expiry := r.checkExpiry(&acmImport, importSource.secretName, leaf, time.Now()); expiry.RequeueAfter > 0 {
+				result = requeueSooner(result, expiry.RequeueAfter)
+			}
+			return result, r.Status().Update(ctx, &acmImport)
+		}
+		zap.S().Infow("Importing certificate into ACM",
+			"acmImport", req.NamespacedName.String(),
+			"revision", revision,
+			"fingerprint", fingerprint,
+		)
+		tags := importTags(&acmImport, revision, r.ClusterId)
+		response, err := acmService.UpsertCertificate(&acm.ImportCertificateInput{
+			Certificate:      certificateData.certificate,
+			CertificateArn:   certificateArn,
+			CertificateChain: certificateData.certificateAuthority,
+			PrivateKey:       certificateData.privateKey,
+			Tags:             tags,
+		})
+		if err != nil {
+			if taggingErr, ok := err.(*aws2.TaggingError); ok {
+				setUntagged(aws.StringValue(taggingErr.CertificateArn), true)
+			}
+			recordEvent(r.Recorder, &acmImport, v1.EventTypeWarning, "ImportFailed",
+				"Failed to import certificate into ACM: %s: %v", aws2.ErrorCode(err), err)
+			return r.importFailed(&acmImport, "ImportFailed", err)
+		}
+		if moved(&acmImport) {
+			r.deletePrevious(&acmImport, importedService)
+		}
+		if r.DryRun {
+			if certificateArn == nil {
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "DryRun",
+					"Would import revision %d into ACM with tags %s", revision, aws2.FormatTags(tags))
+			} else {
+				recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "DryRun",
+					"Would reimport revision %d into %s with tags %s", revision, *certificateArn, aws2.FormatTags(tags))
+			}
+			return result, nil
+		}
+		setUntagged(aws.StringValue(response.CertificateArn), false)
+		setSkipReason(key, "")
+		if migratingFrom != "" {
+			recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Migrated",
+				"Migrated certificate %s", migratingFrom)
+		}
+		if certificateArn == nil {
+			recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Imported",
+				"Imported revision %d into ACM as %s", revision, aws.StringValue(response.CertificateArn))
+			importsTotal.WithLabelValues("AcmImport").Inc()
+		} else {
+			recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Reimported",
+				"Reimported revision %d into ACM as %s", revision, aws.StringValue(response.CertificateArn))
+			reimportsTotal.WithLabelValues("AcmImport").Inc()
+		}
+		notAfter := metav1.NewTime(leaf.NotAfter)
+		lastSyncTime := metav1.Now()
+		acmImport.Status.CertificateArn = aws.StringValue(response.CertificateArn)
+		acmImport.Status.Region = acmImport.Spec.Region
+		acmImport.Status.Account = acmImport.Spec.Account
+		acmImport.Status.ImportedRevision = revision
+		acmImport.Status.Fingerprint = fingerprint
+		acmImport.Status.NotAfter = &notAfter
+		acmImport.Status.LastSyncTime = &lastSyncTime
+	} else if reason := fmt.Sprintf("ACM already holds revision %d with fingerprint %s", revision, fingerprint); setSkipReason(key, reason) {
+		recordEvent(r.Recorder, &acmImport, v1.EventTypeNormal, "Skipped", "%s", reason)
+	}
+
+	description, err := acmService.DescribeCertificate(&acm.DescribeCertificateInput{
+		CertificateArn: aws.String(acmImport.Status.CertificateArn),
+	})
+	if err == nil && description.Certificate != nil {
+		acmImport.Status.InUseBy = aws.StringValueSlice(description.Certificate.InUseBy)
+	} else if err != nil {
+		zap.S().Errorw("Failed to describe certificate in ACM",
+			"acmImport", req.NamespacedName.String(),
+			"arn", acmImport.Status.CertificateArn,
+			"error", err,
+		)
+	}
+
+	if acmImport.Status.NotAfter != nil {
+		setNotAfter("AcmImport", acmImport.Namespace, acmImport.Name, &acmImport.Status.NotAfter.Time)
+	}
+	acmImport.Status.ObservedGeneration = acmImport.Generation
+	r.setReadyCondition(&acmImport, metav1.ConditionTrue, "Imported", "Certificate is imported into ACM")
+	if expiry := r.checkExpiry(&acmImport, importSource.secretName, leaf, time.Now()); expiry.RequeueAfter > 0 {
+		result = requeueSooner(result, expiry.RequeueAfter)
+	}
+	return result, r.Status().Update(ctx, &acmImport)
+}
+
+// importFailed records a failed import on the AcmImport's Ready condition and requeues it according to
+// the error
+func (r *AcmImportReconciler) importFailed(acmImport *acmv1alpha1.AcmImport, reason string, err error) (ctrl.Result, error) {
+	zap.S().Errorw("Error occurred importing certificate",
+		"acmImport", acmImportId(acmImport),
+		"class", aws2.Classify(err),
+		"error", err,
+	)
+	r.setReadyCondition(acmImport, metav1.ConditionFalse, reason, err.Error())
+	if statusErr := r.Status().Update(context.Background(), acmImport); statusErr != nil {
+		zap.S().Errorw("Error occurred updating status", "acmImport", acmImportId(acmImport), "error", statusErr)
+	}
+	return requeueForError(err)
+}
+
+// acmImportsForCertificate maps a Certificate to the AcmImports in its namespace that reference it
+func (r *AcmImportReconciler) acmImportsForCertificate(obj handler.MapObject) []reconcile.Request {
+	var acmImports acmv1alpha1.AcmImportList
+	if err := r.List(context.Background(), &acmImports, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		zap.S().Errorw("Failed to list AcmImports", "namespace", obj.Meta.GetNamespace(), "error", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, acmImport := range acmImports.Items {
+		if acmImport.Spec.CertificateRef != nil && acmImport.Spec.CertificateRef.Name == obj.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: acmImport.Namespace,
+				Name:      acmImport.Name,
+			}})
+		}
+	}
+	return requests
+}
+
+func (r *AcmImportReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&acmv1alpha1.AcmImport{}).
+		Watches(&source.Kind{Type: &cmapiv1.Certificate{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.acmImportsForCertificate),
+		}).
+		Complete(r)
+}
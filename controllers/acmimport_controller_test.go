@@ -0,0 +1,419 @@
+package controllers_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	aws2 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	"github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"math/big"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockServiceFactory struct {
+	service aws.IAcmService
+	regions map[string]aws.IAcmService
+}
+
+func (f *MockServiceFactory) ServiceFor(region string, account string) (aws.IAcmService, error) {
+	if service, ok := f.regions[region]; ok {
+		return service, nil
+	}
+	return f.service, nil
+}
+
+// newTLSSecret returns a Secret holding a self-signed certificate for dnsNames
+func newTLSSecret(t *testing.T, namespace string, name string, notBefore time.Time, notAfter time.Time, dnsNames ...string) *corev1.Secret {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			"tls.key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+			"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+}
+
+func TestAcmImportFromSecret(t *testing.T) {
+	acmImport := &acmv1alpha1.AcmImport{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: acmv1alpha1.AcmImportSpec{
+			SecretRef: &corev1.LocalObjectReference{Name: "secret"},
+			Tags: map[string]string{
+				"team": "platform",
+			},
+		},
+	}
+	secret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	acmv1alpha1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, acmImport, secret)
+	mockService := &MockService{}
+	controller := controllers.AcmImportReconciler{
+		Client:      client,
+		APIReader:   client,
+		AcmServices: &MockServiceFactory{service: mockService},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	if mockService.input == nil {
+		t.Fatal("Certificate was not imported")
+	}
+	if !hasTag("legalzoom.com/cert-importer/acm-import", "foo/bar", mockService.input.Tags) {
+		t.Error("Incorrect acm-import tag")
+	}
+	if !hasTag("team", "platform", mockService.input.Tags) {
+		t.Error("Missing spec tag")
+	}
+
+	var updated acmv1alpha1.AcmImport
+	if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.CertificateArn != "test" {
+		t.Error("Incorrect certificate arn in status")
+	}
+	if updated.Status.Fingerprint == "" || updated.Status.NotAfter == nil {
+		t.Error("Missing fingerprint or NotAfter in status")
+	}
+	if !meta.IsStatusConditionTrue(updated.Status.Conditions, acmv1alpha1.ConditionReady) {
+		t.Error("AcmImport is not ready")
+	}
+}
+
+func TestAcmImportRegionChange(t *testing.T) {
+	cases := []struct {
+		deletionPolicy acmv1alpha1.DeletionPolicy
+		deleted        bool
+	}{
+		{acmv1alpha1.DeletionPolicyDelete, true},
+		{acmv1alpha1.DeletionPolicyRetain, false},
+	}
+
+	for _, c := range cases {
+		acmImport := &acmv1alpha1.AcmImport{
+			ObjectMeta: v1.ObjectMeta{
+				Name:       "bar",
+				Namespace:  "foo",
+				Generation: 2,
+			},
+			Spec: acmv1alpha1.AcmImportSpec{
+				SecretRef:      &corev1.LocalObjectReference{Name: "secret"},
+				Region:         "eu-west-1",
+				DeletionPolicy: c.deletionPolicy,
+			},
+			Status: acmv1alpha1.AcmImportStatus{
+				CertificateArn:     "previous",
+				ObservedGeneration: 1,
+			},
+		}
+		secret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		acmv1alpha1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, acmImport, secret)
+		previousService := &MockService{
+			tags: map[string][]*acm.Tag{
+				"previous": {{Key: aws2.String("legalzoom.com/cert-importer/acm-import"), Value: aws2.String("foo/bar")}},
+			},
+		}
+		mockService := &MockService{}
+		controller := controllers.AcmImportReconciler{
+			Client:    client,
+			APIReader: client,
+			AcmServices: &MockServiceFactory{
+				service: previousService,
+				regions: map[string]aws.IAcmService{"eu-west-1": mockService},
+			},
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "bar",
+		}}
+		if _, err := controller.Reconcile(request); err != nil {
+			t.Fatal(err)
+		}
+
+		if mockService.input == nil || mockService.input.CertificateArn != nil {
+			t.Errorf("%s: expected a new import into the new region", c.deletionPolicy)
+		}
+		if previousService.input != nil {
+			t.Errorf("%s: expected nothing to be imported into the previous region", c.deletionPolicy)
+		}
+		if deleted := len(previousService.deleted) == 1 && previousService.deleted[0] == "previous"; deleted != c.deleted {
+			t.Errorf("%s: expected the previous certificate deleted to be %v, got %v", c.deletionPolicy, c.deleted, previousService.deleted)
+		}
+		if len(mockService.deleted) != 0 {
+			t.Errorf("%s: unexpected delete in the new region %v", c.deletionPolicy, mockService.deleted)
+		}
+
+		var updated acmv1alpha1.AcmImport
+		if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+			t.Fatal(err)
+		}
+		if updated.Status.CertificateArn != "test" || updated.Status.Region != "eu-west-1" {
+			t.Errorf("%s: unexpected status %s in %q", c.deletionPolicy, updated.Status.CertificateArn, updated.Status.Region)
+		}
+	}
+}
+
+func TestAcmImportNotYetValid(t *testing.T) {
+	acmImport := &acmv1alpha1.AcmImport{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: acmv1alpha1.AcmImportSpec{
+			SecretRef: &corev1.LocalObjectReference{Name: "secret"},
+		},
+	}
+	secret := newTLSSecret(t, "foo", "secret", time.Now().Add(30*time.Second), time.Now().Add(24*time.Hour), "example.com")
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	acmv1alpha1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, acmImport, secret)
+	mockService := &MockService{}
+	controller := controllers.AcmImportReconciler{
+		Client:      client,
+		APIReader:   client,
+		AcmServices: &MockServiceFactory{service: mockService},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	result, err := controller.Reconcile(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockService.input != nil {
+		t.Error("Certificate that is not yet valid was imported")
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > 30*time.Second {
+		t.Errorf("Expected a requeue once the certificate is valid, got %v", result.RequeueAfter)
+	}
+
+	var updated acmv1alpha1.AcmImport
+	if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, acmv1alpha1.ConditionReady)
+	if condition == nil || condition.Reason != "NotYetValid" {
+		t.Errorf("Unexpected Ready condition %v", condition)
+	}
+}
+
+func TestAcmImportOwnershipConflict(t *testing.T) {
+	cases := []struct {
+		name       string
+		tags       []*acm.Tag
+		reimported bool
+	}{
+		{"own", []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/acm-import"), Value: aws2.String("foo/bar")},
+			{Key: aws2.String("legalzoom.com/cert-importer/cluster-id"), Value: aws2.String("prod")},
+		}, true},
+		{"other AcmImport", []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/acm-import"), Value: aws2.String("foo/other")},
+			{Key: aws2.String("legalzoom.com/cert-importer/cluster-id"), Value: aws2.String("prod")},
+		}, false},
+		{"Certificate", []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String("foo/cert")},
+			{Key: aws2.String("legalzoom.com/cert-importer/cluster-id"), Value: aws2.String("prod")},
+		}, false},
+		{"other cluster", []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/acm-import"), Value: aws2.String("foo/bar")},
+			{Key: aws2.String("legalzoom.com/cert-importer/cluster-id"), Value: aws2.String("staging")},
+		}, false},
+	}
+
+	for _, c := range cases {
+		acmImport := &acmv1alpha1.AcmImport{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "bar",
+				Namespace: "foo",
+			},
+			Spec: acmv1alpha1.AcmImportSpec{
+				SecretRef: &corev1.LocalObjectReference{Name: "secret"},
+			},
+			Status: acmv1alpha1.AcmImportStatus{
+				CertificateArn: "existing",
+				Fingerprint:    "previous",
+			},
+		}
+		secret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		acmv1alpha1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, acmImport, secret)
+		mockService := &MockService{tags: map[string][]*acm.Tag{"existing": c.tags}}
+		recorder := record.NewFakeRecorder(10)
+		controller := controllers.AcmImportReconciler{
+			Client:      client,
+			APIReader:   client,
+			AcmServices: &MockServiceFactory{service: mockService},
+			Recorder:    recorder,
+			ClusterId:   "prod",
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "bar",
+		}}
+		if _, err := controller.Reconcile(request); err != nil {
+			t.Fatal(err)
+		}
+
+		reimported := mockService.input != nil && aws2.StringValue(mockService.input.CertificateArn) == "existing"
+		if reimported != c.reimported {
+			t.Errorf("%s: expected reimported to be %v", c.name, c.reimported)
+		}
+		if !c.reimported {
+			var updated acmv1alpha1.AcmImport
+			if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(updated.Status.Conditions, acmv1alpha1.ConditionReady)
+			if condition == nil || condition.Reason != "OwnershipConflict" {
+				t.Errorf("%s: unexpected Ready condition %v", c.name, condition)
+			}
+			if len(recorder.Events) != 1 || !strings.HasPrefix(<-recorder.Events, "Warning OwnershipConflict") {
+				t.Errorf("%s: expected an OwnershipConflict event", c.name)
+			}
+		}
+	}
+}
+
+func TestAcmImportPausedAndSkipped(t *testing.T) {
+	acmImport := &acmv1alpha1.AcmImport{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "skipped",
+			Namespace: "foo",
+		},
+		Spec: acmv1alpha1.AcmImportSpec{
+			SecretRef: &corev1.LocalObjectReference{Name: "secret"},
+		},
+	}
+	secret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(90*24*time.Hour), "example.com")
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	acmv1alpha1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, acmImport, secret)
+	mockService := &MockService{}
+	recorder := record.NewFakeRecorder(10)
+	controller := controllers.AcmImportReconciler{
+		Client:      client,
+		APIReader:   client,
+		AcmServices: &MockServiceFactory{service: mockService},
+		Recorder:    recorder,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "skipped",
+	}}
+	for i := 0; i < 3; i++ {
+		if _, err := controller.Reconcile(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(recorder.Events) != 2 {
+		t.Fatalf("Expected an Imported and a single Skipped event, got %d events", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Imported") {
+		t.Errorf("Unexpected event %s", event)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Skipped") {
+		t.Errorf("Unexpected event %s", event)
+	}
+
+	var updated acmv1alpha1.AcmImport
+	if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Annotations = map[string]string{"legalzoom.com/acm-paused": "true"}
+	if err := client.Update(context.Background(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Status.Fingerprint = "changed"
+	if err := client.Status().Update(context.Background(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	mockService.input = nil
+	for i := 0; i < 2; i++ {
+		if _, err := controller.Reconcile(request); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mockService.input != nil {
+		t.Error("Paused AcmImport was reimported")
+	}
+	if len(recorder.Events) != 1 || !strings.HasPrefix(<-recorder.Events, "Normal Paused") {
+		t.Error("Expected a single Paused event")
+	}
+
+	// Deleted AcmImports are no longer counted as paused
+	if err := client.Delete(context.Background(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+}
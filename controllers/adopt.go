@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+)
+
+var (
+	certificateArnAnnotation = "legalzoom.com/certificate-arn"
+	adoptAnnotation          = "legalzoom.com/acm-adopt"
+)
+
+// checkOwnership returns an error when an ACM certificate with the given tags does not belong to the
+// Certificate with the given cert-id in this cluster, or to the Certificate it migrates from. A
+// Certificate annotated to adopt a certificate may also take over one without a cert-id tag, or one
+// tagged for another Certificate in its own namespace, but never one owned by another cluster. A
+// certificate without a cert-id tag is otherwise only accepted from the cache, which is keyed by that tag.
+func checkOwnership(certificate *cmapiv1.Certificate, certId string, migratingFrom string, clusterId string, claimUntagged bool, certificateArn string, tags []*acm.Tag, cached bool) error {
+	adopt := certificate.Annotations[adoptAnnotation] == "true"
+	if !clusterMatches(clusterId, claimUntagged, tags) {
+		owner, ok := tagValue(tags, clusterIdTag)
+		if ok {
+			return fmt.Errorf("certificate %s belongs to cluster %s", certificateArn, owner)
+		}
+		if !adopt {
+			return fmt.Errorf("certificate %s has no %s tag; set %s to adopt it", certificateArn, clusterIdTag, adoptAnnotation)
+		}
+	}
+	if owner, ok := tagValue(tags, certIdAnnotation); ok {
+		if owner == certId || (migratingFrom != "" && owner == migratingFrom) {
+			return nil
+		}
+		if adopt && sameNamespace(certificate, owner) {
+			return nil
+		}
+		return fmt.Errorf("certificate %s belongs to %s", certificateArn, owner)
+	}
+	if cached || adopt {
+		return nil
+	}
+	return fmt.Errorf("certificate %s has no %s tag; set %s to adopt it", certificateArn, certIdAnnotation, adoptAnnotation)
+}
+
+// adoptCertificate looks up an existing ACM certificate named by the certificate-arn annotation of a
+// Certificate that is not in the cache, so that it is reimported into rather than imported anew.
+// The region is taken from the ARN.
+func (r *CertificateReconciler) adoptCertificate(certificateArn string) (*AcmCertificate, error) {
+	parsed, err := arn.Parse(certificateArn)
+	if err != nil || parsed.Service != "acm" {
+		return nil, fmt.Errorf("%s is not an ACM certificate ARN", certificateArn)
+	}
+
+	acmService, err := r.acmServiceFor(parsed.Region)
+	if err != nil {
+		return nil, err
+	}
+	output, err := acmService.ListTagsForCertificate(&acm.ListTagsForCertificateInput{
+		CertificateArn: aws.String(certificateArn),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws.String(certificateArn),
+		},
+		Tags:   output.Tags,
+		Region: parsed.Region,
+	}, nil
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+)
+
+// clusterIdTag identifies the cluster that imported a certificate, so that clusters sharing an
+// account leave each other's certificates alone
+var clusterIdTag = "legalzoom.com/cert-importer/cluster-id"
+
+// tagValue returns the value of the tag with the given key
+func tagValue(tags []*acm.Tag, key string) (string, bool) {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return aws.StringValue(tag.Value), true
+		}
+	}
+	return "", false
+}
+
+// clusterMatches reports whether a certificate with the given tags belongs to the cluster. Certificates
+// imported before cluster IDs were used carry no cluster-id tag. They only belong to a cluster without a
+// cluster ID, or to one that claims them, so that clusters sharing an account do not take them over alike.
+func clusterMatches(clusterId string, claimUntagged bool, tags []*acm.Tag) bool {
+	value, ok := tagValue(tags, clusterIdTag)
+	if !ok {
+		return clusterId == "" || claimUntagged
+	}
+	return value == clusterId
+}
+
+// clusterTags returns the tag identifying the cluster, none when no cluster ID is configured
+func clusterTags(clusterId string) map[string]string {
+	if clusterId == "" {
+		return nil
+	}
+	return map[string]string{clusterIdTag: clusterId}
+}
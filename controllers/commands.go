@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sort"
+	"strings"
+)
+
+// Revision returns the Certificate revision recorded in the tags of the ACM certificate
+func (c *AcmCertificate) Revision() (int, bool) {
+	revision := revisionTag(c.Tags)
+	return revision, revision >= 0
+}
+
+// ErrPaused is returned for Certificates that are skipped because their syncing with ACM is paused
+var ErrPaused = errors.New("paused")
+
+// SyncAll reconciles every Certificate once, returning the errors by cert-id. Paused Certificates are
+// not reconciled, and ErrPaused is returned for them.
+func (r *CertificateReconciler) SyncAll() (map[string]error, error) {
+	var certificates cmapiv1.CertificateList
+	if err := r.List(context.Background(), &certificates); err != nil {
+		return nil, err
+	}
+
+	errs := map[string]error{}
+	for _, certificate := range certificates.Items {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: certificate.Namespace, Name: certificate.Name}}
+		if settings, err := r.resolveImportSettings(&certificate); err == nil && syncPaused(&certificate, settings) {
+			errs[req.NamespacedName.String()] = ErrPaused
+			continue
+		}
+		if _, err := r.Reconcile(req); err != nil {
+			errs[req.NamespacedName.String()] = err
+		}
+	}
+	return errs, nil
+}
+
+// Import imports a managed Certificate into ACM even when ACM already holds its current revision.
+// ErrPaused is returned when syncing the Certificate is paused.
+func (r *CertificateReconciler) Import(namespace string, name string) error {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+	var certificate cmapiv1.Certificate
+	if err := r.Get(context.Background(), req.NamespacedName, &certificate); err != nil {
+		return err
+	}
+	settings, err := r.resolveImportSettings(&certificate)
+	if err != nil || !settings.managed {
+		return fmt.Errorf("certificate %s is not managed", req.NamespacedName.String())
+	}
+	if syncPaused(&certificate, settings) {
+		return ErrPaused
+	}
+
+	r.ForceImport = true
+	defer func() { r.ForceImport = false }()
+	_, err = r.Reconcile(req)
+	return err
+}
+
+// Orphan is an ACM certificate in the cache whose Certificate no longer exists or is no longer managed
+type Orphan struct {
+	CertId      string
+	Certificate *AcmCertificate
+	Reason      string
+}
+
+// Orphans returns the orphaned ACM certificates in the cache, sorted by cert-id
+func (r *CertificateReconciler) Orphans() ([]Orphan, error) {
+	mutex.RLock()
+	certIds := make([]string, 0, len(r.Cache))
+	for certId, entry := range r.Cache {
+		if entry != nil {
+			certIds = append(certIds, certId)
+		}
+	}
+	mutex.RUnlock()
+	sort.Strings(certIds)
+
+	var orphans []Orphan
+	for _, certId := range certIds {
+		parts := strings.SplitN(certId, "/", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		reason := ""
+		var certificate cmapiv1.Certificate
+		err := r.APIReader.Get(context.Background(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, &certificate)
+		if apierrors.IsNotFound(err) {
+			reason = "Certificate not found"
+		} else if err != nil {
+			return nil, err
+		} else if !contains(certificate.Finalizers, finalizer) {
+			settings, err := r.resolveImportSettings(&certificate)
+			if err != nil {
+				return nil, err
+			}
+			if !settings.managed {
+				reason = "Certificate not managed"
+			}
+		}
+		if reason != "" {
+			mutex.RLock()
+			entry := r.Cache[certId]
+			mutex.RUnlock()
+			orphans = append(orphans, Orphan{CertId: certId, Certificate: entry, Reason: reason})
+		}
+	}
+	return orphans, nil
+}
+
+// DeleteOrphan deletes an orphaned ACM certificate and removes it from the cache
+func (r *CertificateReconciler) DeleteOrphan(orphan Orphan) error {
+	acmService, err := r.acmServiceFor(orphan.Certificate.Region)
+	if err != nil {
+		return err
+	}
+	_, err = acmService.DeleteCertificate(&acm.DeleteCertificateInput{
+		CertificateArn: orphan.Certificate.Summary.CertificateArn,
+	})
+	if _, ok := err.(*acm.ResourceNotFoundException); err != nil && !ok {
+		return fmt.Errorf("failed to delete %s: %v", aws.StringValue(orphan.Certificate.Summary.CertificateArn), err)
+	}
+	if r.DryRun {
+		return nil
+	}
+	deletesTotal.WithLabelValues("Certificate").Inc()
+	mutex.Lock()
+	delete(r.Cache, orphan.CertId)
+	mutex.Unlock()
+	setCertificateNotAfter(orphan.CertId, nil)
+	forgetExpiry(orphan.CertId)
+	return nil
+}
+
+// Untagged returns the cert-ids of the ACM certificates in the cache that were reimported but still
+// carry their previous tags, sorted. Certificates recorded as untagged on their Certificate are marked
+// in the cache first, so that they are found after a restart.
+func (r *CertificateReconciler) Untagged() ([]string, error) {
+	var certificates cmapiv1.CertificateList
+	if err := r.APIReader.List(context.Background(), &certificates); err != nil {
+		return nil, err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, certificate := range certificates.Items {
+		certificateArn := certificate.Annotations[untaggedAnnotation]
+		entry := r.Cache[types.NamespacedName{Namespace: certificate.Namespace, Name: certificate.Name}.String()]
+		if certificateArn != "" && entry != nil && aws.StringValue(entry.Summary.CertificateArn) == certificateArn {
+			entry.Untagged = true
+		}
+	}
+	var certIds []string
+	for certId, entry := range r.Cache {
+		if entry != nil && entry.Untagged {
+			certIds = append(certIds, certId)
+		}
+	}
+	sort.Strings(certIds)
+	return certIds, nil
+}
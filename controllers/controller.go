@@ -4,29 +4,52 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/acm"
 	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
 	"github.com/go-logr/logr"
 	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
 	cmmetav1 "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type AcmCertificate struct {
 	Summary *acm.CertificateSummary
 	Tags    []*acm.Tag
+	// Region is the region the certificate was imported into, empty for the default region
+	Region string
+	// NotAfter is the expiry of the certificate held in ACM, when known
+	NotAfter *time.Time
+	// ImportedAt is when the certificate was last imported into ACM, when known
+	ImportedAt *time.Time
+	// Untagged is set when the certificate was reimported but still carries its previous tags
+	Untagged bool
+	// synced is the import into the certificate by this controller, nil when loaded from ACM
+	synced *syncRecord
 }
 
 // CertificateReconciler reconciles a CronJob object
@@ -37,29 +60,105 @@ type CertificateReconciler struct {
 	Scheme     *runtime.Scheme
 	Cache      map[string]*AcmCertificate
 	AcmService aws2.IAcmService
+	// AcmServices provides the ACM services for regions other than the default
+	AcmServices aws2.IAcmServiceFactory
+	// Regions are the additional regions whose certificates are loaded into the cache
+	Regions []string
+	// DomainPolicy restricts the DNS names and issuers each namespace may import, when set
+	DomainPolicy *DomainPolicy
+	Recorder     record.EventRecorder
+	// ClusterId is tagged onto imported certificates. Certificates tagged with another cluster's ID
+	// are ignored.
+	ClusterId string
+	// ClaimUntagged takes over certificates without a cluster-id tag despite a ClusterId being set, so
+	// that they are tagged with it on their next reimport
+	ClaimUntagged bool
+	// DeleteDuplicates deletes ACM certificates found at startup carrying the same cert-id as the
+	// one that is kept, unless they are in use
+	DeleteDuplicates bool
+	// DryRun only logs and records events for the changes that would be made in ACM
+	DryRun bool
+	// ForceImport imports managed Certificates even when ACM already holds their current revision
+	ForceImport bool
+	// MaintenanceWindowOverride is how close to its NotAfter an ACM certificate is reimported regardless
+	// of its maintenance window, DefaultMaintenanceWindowOverride when zero
+	MaintenanceWindowOverride time.Duration
+	// ExpiryThresholds are how long before the ACM copy of a Certificate expires it is warned about and
+	// requeued, DefaultExpiryThresholds when nil
+	ExpiryThresholds []time.Duration
 }
 
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificate,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 var (
+	dryRunAnnotation       = "legalzoom.com/acm-dry-run"
+	pausedAnnotation       = "legalzoom.com/acm-paused"
 	certIdAnnotation       = "legalzoom.com/cert-importer/cert-id"
 	certRevisionAnnotation = "legalzoom.com/cert-importer/cert-revision"
 	finalizer              = "certificate.legalzoom.com"
 	mutex                  = &sync.RWMutex{}
+	// skipReasons are the reasons last reported for not importing each Certificate, by cert-id, so that
+	// the Skipped event is only recorded when the reason changes
+	skipReasons      = map[string]string{}
+	skipReasonsMutex = &sync.Mutex{}
 )
 
+// setSkipReason records why the Certificate with the given cert-id was not imported, or the empty reason
+// once it is imported or gone, and returns whether the reason changed
+func setSkipReason(certId string, reason string) bool {
+	skipReasonsMutex.Lock()
+	defer skipReasonsMutex.Unlock()
+	if skipReasons[certId] == reason {
+		return false
+	}
+	if reason == "" {
+		delete(skipReasons, certId)
+	} else {
+		skipReasons[certId] = reason
+	}
+	return true
+}
+
+// listCertificatesInput returns the input listing a page of certificates of every key algorithm, since
+// ACM only lists RSA_2048 certificates unless other key types are asked for
+func listCertificatesInput(nextToken *string) *acm.ListCertificatesInput {
+	return &acm.ListCertificatesInput{
+		NextToken: nextToken,
+		Includes: &acm.Filters{
+			KeyTypes: aws.StringSlice([]string{
+				acm.KeyAlgorithmRsa1024,
+				acm.KeyAlgorithmRsa2048,
+				acm.KeyAlgorithmRsa4096,
+				acm.KeyAlgorithmEcPrime256v1,
+				acm.KeyAlgorithmEcSecp384r1,
+				acm.KeyAlgorithmEcSecp521r1,
+			}),
+		},
+	}
+}
+
 func (r *CertificateReconciler) InitializeCache() {
-	sess := session.Must(session.NewSession())
-	acmClient := acm.New(sess)
+	r.loadCache(r.AcmService, "")
+	for _, region := range r.Regions {
+		acmService, err := r.acmServiceFor(region)
+		if err != nil {
+			zap.S().Errorw("Failed to load certificates", "region", region, "error", err)
+			continue
+		}
+		r.loadCache(acmService, region)
+	}
+}
 
+// loadCache adds the certificates in one region carrying a cert-id tag to the cache
+func (r *CertificateReconciler) loadCache(acmService aws2.IAcmService, region string) {
 	getNextPage := true
 	nextToken := aws.String("")
 	nextToken = nil
 
-	r.AcmService = &aws2.AcmService{Client: acmClient}
 	for getNextPage == true {
-		certs, err := acmClient.ListCertificates(&acm.ListCertificatesInput{NextToken: nextToken})
+		certs, err := acmService.ListCertificates(listCertificatesInput(nextToken))
 		if err == nil {
 			if certs.NextToken != nil && len(*certs.NextToken) > 0 {
 				getNextPage = true
@@ -68,20 +167,69 @@ func (r *CertificateReconciler) InitializeCache() {
 				getNextPage = false
 			}
 			for _, cert := range certs.CertificateSummaryList {
-				output, _ := acmClient.ListTagsForCertificate(&acm.ListTagsForCertificateInput{CertificateArn: cert.CertificateArn})
+				output, err := acmService.ListTagsForCertificate(&acm.ListTagsForCertificateInput{CertificateArn: cert.CertificateArn})
+				if err != nil {
+					zap.S().Errorw("Failed to list tags for certificate", "arn", aws.StringValue(cert.CertificateArn), "error", err)
+					continue
+				}
+				if !clusterMatches(r.ClusterId, r.ClaimUntagged, output.Tags) {
+					continue
+				}
 				for _, tag := range output.Tags {
 					if *tag.Key == certIdAnnotation {
-						r.Cache[*tag.Value] = &AcmCertificate{
+						entry := &AcmCertificate{
 							Summary: cert,
 							Tags:    output.Tags,
+							Region:  region,
+						}
+						description, err := acmService.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: cert.CertificateArn})
+						if err == nil && description.Certificate != nil {
+							entry.NotAfter = description.Certificate.NotAfter
+							entry.ImportedAt = description.Certificate.ImportedAt
 						}
+						if existing := r.Cache[*tag.Value]; existing != nil {
+							entry = r.resolveDuplicate(*tag.Value, existing, entry)
+						}
+						r.Cache[*tag.Value] = entry
+						setCertificateNotAfter(*tag.Value, entry.NotAfter)
 					}
 				}
 			}
 		} else {
-			zap.S().Error("error received", err)
+			zap.S().Errorw("Failed to list certificates", "region", region, "error", err)
+			return
+		}
+	}
+}
+
+// acmServiceFor returns the ACM service for a region, the default service for the empty region
+func (r *CertificateReconciler) acmServiceFor(region string) (aws2.IAcmService, error) {
+	acmService := r.AcmService
+	if region != "" && r.AcmServices != nil {
+		var err error
+		if acmService, err = r.AcmServices.ServiceFor(region, ""); err != nil {
+			return nil, err
 		}
 	}
+	if r.DryRun {
+		return &aws2.DryRunService{Service: acmService}, nil
+	}
+	return acmService, nil
+}
+
+// serviceFor returns the ACM service for a Certificate in a region, which only logs changes when
+// dry-run mode is enabled for the Certificate
+func (r *CertificateReconciler) serviceFor(certificate *cmapiv1.Certificate, region string) (aws2.IAcmService, error) {
+	acmService, err := r.acmServiceFor(region)
+	if err != nil || r.DryRun || certificate.Annotations[dryRunAnnotation] != "true" {
+		return acmService, err
+	}
+	return &aws2.DryRunService{Service: acmService}, nil
+}
+
+// dryRun reports whether changes to the Certificate's ACM certificate are only logged
+func (r *CertificateReconciler) dryRun(certificate *cmapiv1.Certificate) bool {
+	return r.DryRun || certificate.Annotations[dryRunAnnotation] == "true"
 }
 
 type Certificate struct {
@@ -90,6 +238,21 @@ type Certificate struct {
 	certificateAuthority []byte
 }
 
+// Leaf parses the leaf certificate
+func (c *Certificate) Leaf() (*x509.Certificate, error) {
+	block, _ := pem.Decode(c.certificate)
+	if block == nil {
+		return nil, errors.New("no PEM data found in certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of a certificate
+func Fingerprint(leaf *x509.Certificate) string {
+	sum := sha256.Sum256(leaf.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
 func (r *CertificateReconciler) GetCertificateSecret(certificate cmapiv1.Certificate) *Certificate {
 	var secret = &v1.Secret{}
 	ctx := context.Background()
@@ -97,6 +260,11 @@ func (r *CertificateReconciler) GetCertificateSecret(certificate cmapiv1.Certifi
 		Namespace: certificate.Namespace,
 		Name:      certificate.Spec.SecretName,
 	}, secret)
+	return parseCertificateSecret(secret)
+}
+
+// parseCertificateSecret splits a kubernetes.io/tls Secret into its private key, leaf certificate and chain
+func parseCertificateSecret(secret *v1.Secret) *Certificate {
 	tlsKey := secret.Data["tls.key"]
 	tlsCrt := secret.Data["tls.crt"]
 
@@ -126,7 +294,37 @@ func (r *CertificateReconciler) GetCertificateSecret(certificate cmapiv1.Certifi
 	}
 }
 
-func (r *CertificateReconciler) GetImportCertificateInput(certificate cmapiv1.Certificate, summary *acm.CertificateSummary, existingTags []*acm.Tag) acm.ImportCertificateInput {
+// recordEvent records an event on the object when a recorder is configured
+func recordEvent(recorder record.EventRecorder, object runtime.Object, eventtype string, reason string, messageFmt string, args ...interface{}) {
+	if recorder != nil {
+		recorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// issuerKind returns the kind of an issuer reference, which defaults to Issuer
+func issuerKind(issuerRef cmmetav1.ObjectReference) string {
+	if issuerRef.Kind == "" {
+		return cmapiv1.IssuerKind
+	}
+	return issuerRef.Kind
+}
+
+// CheckDomainPolicy returns an error when the domain policy does not allow importing the certificate
+func (r *CertificateReconciler) CheckDomainPolicy(certificate *cmapiv1.Certificate) error {
+	if r.DomainPolicy == nil {
+		return nil
+	}
+	leaf, err := r.GetCertificateSecret(*certificate).Leaf()
+	if err != nil {
+		return fmt.Errorf("cannot verify certificate against the domain policy: %v", err)
+	}
+	return r.DomainPolicy.Allows(certificate.Namespace, issuerKind(certificate.Spec.IssuerRef), certificate.Spec.IssuerRef.Name, leaf)
+}
+
+// GetImportCertificateInput returns the input importing the Certificate, reimporting into summary when
+// set. The existing tags are kept and extraTags added; the controller's own tags are applied last, so
+// that they cannot be replaced.
+func (r *CertificateReconciler) GetImportCertificateInput(certificate cmapiv1.Certificate, summary *acm.CertificateSummary, existingTags []*acm.Tag, extraTags map[string]string) acm.ImportCertificateInput {
 	var certRevision int
 	var certificateArn *string
 
@@ -137,19 +335,6 @@ func (r *CertificateReconciler) GetImportCertificateInput(certificate cmapiv1.Ce
 	certificateData := r.GetCertificateSecret(certificate)
 	tags := []*acm.Tag{}
 
-	tags = append(tags, &acm.Tag{
-		Key:   aws.String(certRevisionAnnotation),
-		Value: aws.String(strconv.Itoa(certRevision)),
-	})
-
-	tags = append(tags, &acm.Tag{
-		Key: aws.String(certIdAnnotation),
-		Value: aws.String(types.NamespacedName{
-			Namespace: certificate.Namespace,
-			Name:      certificate.Name,
-		}.String()),
-	})
-
 	if summary != nil {
 		certificateArn = summary.CertificateArn
 		for _, tag := range existingTags {
@@ -159,6 +344,15 @@ func (r *CertificateReconciler) GetImportCertificateInput(certificate cmapiv1.Ce
 		}
 	}
 
+	tags = mergeTags(tags, extraTags)
+	tags = mergeTags(tags, map[string]string{
+		certRevisionAnnotation: strconv.Itoa(certRevision),
+		certIdAnnotation: types.NamespacedName{
+			Namespace: certificate.Namespace,
+			Name:      certificate.Name,
+		}.String(),
+	})
+
 	return acm.ImportCertificateInput{
 		Certificate:      certificateData.certificate,
 		CertificateArn:   certificateArn,
@@ -188,7 +382,21 @@ func removeString(slice []string, s string) (result []string) {
 }
 
 func (r *CertificateReconciler) CertificateNeedsUpdated(req ctrl.Request, certificate *cmapiv1.Certificate) bool {
+	mutex.RLock()
 	existingCert := r.Cache[req.NamespacedName.String()]
+	mutex.RUnlock()
+	return r.needsUpdate(certificate, existingCert)
+}
+
+// needsUpdate decides whether to import the Certificate given its cache entry, which callers read while
+// holding the lock
+func (r *CertificateReconciler) needsUpdate(certificate *cmapiv1.Certificate, existingCert *AcmCertificate) bool {
+	if r.ForceImport {
+		return true
+	}
+	if requested, _ := reimportRequested(certificate, time.Now()); requested && existingCert != nil {
+		return true
+	}
 	if existingCert != nil && certificate.Status.Revision != nil {
 		resolvedAcmTags := existingCert.Tags
 
@@ -213,9 +421,32 @@ func (r *CertificateReconciler) CertificateNeedsUpdated(req ctrl.Request, certif
 	}
 }
 
+// updateSyncError records a sync error on the Certificate's annotations
+func (r *CertificateReconciler) updateSyncError(certificate *cmapiv1.Certificate, err error) error {
+	if !recordSyncError(certificate, err) {
+		return nil
+	}
+	return r.Update(context.Background(), certificate)
+}
+
+// skipReason describes why CertificateNeedsUpdated returned false
+func (r *CertificateReconciler) skipReason(req ctrl.Request, certificate *cmapiv1.Certificate) string {
+	mutex.RLock()
+	existingCert := r.Cache[req.NamespacedName.String()]
+	mutex.RUnlock()
+	if existingCert != nil && certificate.Status.Revision != nil {
+		for _, tag := range existingCert.Tags {
+			if *tag.Key == certRevisionAnnotation {
+				return fmt.Sprintf("ACM already holds revision %s", *tag.Value)
+			}
+		}
+	}
+	return "Certificate is not ready"
+}
+
 func (r *CertificateReconciler) CertificateIsManaged(certificate *cmapiv1.Certificate) bool {
-	importToAcm := certificate.Annotations["legalzoom.com/import-to-acm"]
-	return importToAcm == "true"
+	settings, err := r.resolveImportSettings(certificate)
+	return err == nil && settings.managed
 }
 
 func (r *CertificateReconciler) AddMetadataIfNeeded(certificate *cmapiv1.Certificate, namespacedName string) bool {
@@ -232,26 +463,65 @@ func (r *CertificateReconciler) AddMetadataIfNeeded(certificate *cmapiv1.Certifi
 		updateRequired = true
 	}
 
-	if certificate.ObjectMeta.Annotations["legalzoom.com/certificate-arn"] == "" && r.Cache[namespacedName] != nil {
+	mutex.RLock()
+	existingCert := r.Cache[namespacedName]
+	mutex.RUnlock()
+	if certificate.ObjectMeta.Annotations[certificateArnAnnotation] == "" && existingCert != nil {
 		zap.S().Info("Setting arn annotation for certificate ", namespacedName)
-		certificate.ObjectMeta.Annotations["legalzoom.com/certificate-arn"] = *r.Cache[namespacedName].Summary.CertificateArn
+		if certificate.ObjectMeta.Annotations == nil {
+			certificate.ObjectMeta.Annotations = map[string]string{}
+		}
+		certificate.ObjectMeta.Annotations[certificateArnAnnotation] = *existingCert.Summary.CertificateArn
 		updateRequired = true
 	}
 
 	return updateRequired
 }
 
+// syncPaused reports whether syncing a Certificate that is managed, or still has to be cleaned up, is paused
+func syncPaused(certificate *cmapiv1.Certificate, settings importSettings) bool {
+	return certificate.ObjectMeta.Annotations[pausedAnnotation] == "true" &&
+		(settings.managed || contains(certificate.ObjectMeta.Finalizers, finalizer))
+}
+
 func (r *CertificateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 
 	var certificate cmapiv1.Certificate
 	if err := r.Get(ctx, req.NamespacedName, &certificate); err != nil {
+		if apierrors.IsNotFound(err) {
+			setManaged(req.NamespacedName.String(), false)
+			setPaused(req.NamespacedName.String(), false)
+			forgetExpiry(req.NamespacedName.String())
+			setSkipReason(req.NamespacedName.String(), "")
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	var resolvedAcmCertificate *acm.CertificateSummary
 	var resolvedAcmTags []*acm.Tag
-	if r.CertificateIsManaged(&certificate) {
+	statusChanged := false
+	settings, err := r.resolveImportSettings(&certificate)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	setManaged(req.NamespacedName.String(), settings.managed && certificate.ObjectMeta.DeletionTimestamp.IsZero())
+	// Paused Certificates keep their finalizer and their ACM certificate as it is, and catch up once
+	// the annotation is removed
+	paused := syncPaused(&certificate, settings)
+	// The Paused event is only recorded when syncing becomes paused, not on every reconcile
+	if setPaused(req.NamespacedName.String(), paused) && paused {
+		zap.S().Info("Syncing is paused for ", req.NamespacedName.String())
+		recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Paused",
+			"Syncing with ACM is paused; imports and deletes are skipped")
+	}
+	if paused {
+		return ctrl.Result{}, nil
+	}
+	if !settings.managed && contains(certificate.ObjectMeta.Finalizers, finalizer) {
+		return r.unmanage(req, &certificate)
+	}
+	if settings.managed {
 		zap.S().Info("Reconciling ", req.NamespacedName.String())
 
 		if !certificate.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -262,34 +532,77 @@ func (r *CertificateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 				mutex.RUnlock()
 				if cachedEntry == nil {
 					zap.S().Info("Didn't find certificate. Must not have been issued. ", req.NamespacedName.String())
+				} else if settings.deletionPolicy == acmv1alpha1.DeletionPolicyRetain {
+					zap.S().Info("Retaining certificate in ACM ", req.NamespacedName.String())
+					acmService, err := r.serviceFor(&certificate, cachedEntry.Region)
+					if err != nil {
+						return ctrl.Result{}, err
+					}
+					if err := releaseCertificate(acmService, cachedEntry.Summary.CertificateArn); err != nil {
+						zap.S().Errorw("Failed to release certificate in ACM",
+							"certificate", req.NamespacedName.String(),
+							"arn", aws.StringValue(cachedEntry.Summary.CertificateArn),
+							"class", aws2.Classify(err),
+							"error", err,
+						)
+						recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "ReleaseFailed",
+							"Failed to release certificate %s in ACM: %s: %v",
+							aws.StringValue(cachedEntry.Summary.CertificateArn), aws2.ErrorCode(err), err)
+						return requeueForError(err)
+					}
+					if r.dryRun(&certificate) {
+						recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "DryRun",
+							"Would retain certificate %s in ACM", aws.StringValue(cachedEntry.Summary.CertificateArn))
+					} else {
+						recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Retained",
+							"Retained certificate %s in ACM", aws.StringValue(cachedEntry.Summary.CertificateArn))
+						mutex.Lock()
+						r.Cache[req.NamespacedName.String()] = nil
+						mutex.Unlock()
+					}
 				} else {
-					_, err := r.AcmService.DeleteCertificate(&acm.DeleteCertificateInput{
+					acmService, err := r.serviceFor(&certificate, cachedEntry.Region)
+					if err != nil {
+						return ctrl.Result{}, err
+					}
+					_, err = acmService.DeleteCertificate(&acm.DeleteCertificateInput{
 						CertificateArn: cachedEntry.Summary.CertificateArn,
 					})
 
-					if err == nil {
+					if err == nil && r.dryRun(&certificate) {
+						recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "DryRun",
+							"Would delete certificate %s from ACM", aws.StringValue(cachedEntry.Summary.CertificateArn))
+					} else if err == nil {
+						recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Deleted",
+							"Deleted certificate %s from ACM", aws.StringValue(cachedEntry.Summary.CertificateArn))
+						deletesTotal.WithLabelValues("Certificate").Inc()
 						mutex.Lock()
 						r.Cache[req.NamespacedName.String()] = nil
 						mutex.Unlock()
 					} else {
 						if _, ok := err.(*acm.ResourceNotFoundException); ok {
-							err = nil
-							zap.S().Errorw("Failed to delete certificate in ACM. Not found. Removing finalizer.",
-								zap.Error(err),
-								zap.String("certificate", req.NamespacedName.String()),
-								zap.String("arn", *cachedEntry.Summary.CertificateArn),
+							zap.S().Infow("Certificate not found in ACM. Removing finalizer.",
+								"certificate", req.NamespacedName.String(),
+								"arn", aws.StringValue(cachedEntry.Summary.CertificateArn),
 							)
 						} else {
 							zap.S().Errorw("Failed to delete certificate in ACM",
-								zap.Error(err),
-								zap.String("certificate", req.NamespacedName.String()),
-								zap.String("arn", *cachedEntry.Summary.CertificateArn),
+								"certificate", req.NamespacedName.String(),
+								"arn", aws.StringValue(cachedEntry.Summary.CertificateArn),
+								"class", aws2.Classify(err),
+								"error", err,
 							)
-							return ctrl.Result{}, err
+							recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "DeleteFailed",
+								"Failed to delete certificate %s from ACM: %s: %v",
+								aws.StringValue(cachedEntry.Summary.CertificateArn), aws2.ErrorCode(err), err)
+							return requeueForError(err)
 						}
 					}
 				}
 
+				setCertificateNotAfter(req.NamespacedName.String(), nil)
+				forgetExpiry(req.NamespacedName.String())
+				setSkipReason(req.NamespacedName.String(), "")
 				certificate.ObjectMeta.Finalizers = removeString(certificate.ObjectMeta.Finalizers, finalizer)
 				if err := r.Update(context.Background(), &certificate); err != nil {
 					return reconcile.Result{}, err
@@ -300,52 +613,351 @@ func (r *CertificateReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 			return ctrl.Result{}, nil
 		}
 
-		if r.CertificateNeedsUpdated(req, &certificate) {
+		// An import whose sync annotations were lost, as when updating the Certificate conflicted with
+		// cert-manager, is recorded again before deciding
+		// whether to import, so that reimport requests it handled are not repeated
+		mutex.RLock()
+		cachedEntry := r.Cache[req.NamespacedName.String()]
+		mutex.RUnlock()
+		if cachedEntry != nil && syncPending(&certificate, cachedEntry.synced) {
+			recordSyncSuccess(&certificate, cachedEntry.synced.revision, cachedEntry.synced.leaf, cachedEntry.synced.at)
+			statusChanged = true
+		}
+		if !r.needsUpdate(&certificate, cachedEntry) {
+			if reason := r.skipReason(req, &certificate); setSkipReason(req.NamespacedName.String(), reason) {
+				recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Skipped", "%s", reason)
+			}
+		} else {
+			if err := r.CheckDomainPolicy(&certificate); err != nil {
+				zap.S().Warnw("Refusing to import certificate",
+					"certificate", req.NamespacedName.String(),
+					"reason", err.Error(),
+				)
+				recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "ImportRefused", "%v", err)
+				importsRefused.WithLabelValues(certificate.Namespace).Inc()
+				return ctrl.Result{}, r.updateSyncError(&certificate, err)
+			}
+
+			// The lock is not held while calling ACM, where waiting for the rate limiter or retries could
+			// otherwise hold up every other reconcile waiting to update the cache
 			mutex.RLock()
-			if !r.CertificateNeedsUpdated(req, &certificate) {
-				mutex.RUnlock()
+			existingCert := r.Cache[req.NamespacedName.String()]
+			mutex.RUnlock()
+			if !r.needsUpdate(&certificate, existingCert) {
 				return reconcile.Result{}, nil
 			}
-			existingCert := r.Cache[req.NamespacedName.String()]
+			region := settings.region
+			cached := existingCert != nil
+			migratingFrom := ""
 			if existingCert != nil {
 				resolvedAcmCertificate = existingCert.Summary
 				resolvedAcmTags = existingCert.Tags
-			} else if certificate.ObjectMeta.Annotations["legalzoom.com/certificate-arn"] != "" {
-				zap.S().Error("Expected to find certificate in cache but was not available. ")
+				region = existingCert.Region
+			} else if migrateFrom := certificate.ObjectMeta.Annotations[migrateFromAnnotation]; migrateFrom != "" {
+				migrated, previousId, err := r.migratedCertificate(&certificate)
+				if err != nil {
+					zap.S().Errorw("Failed to migrate certificate",
+						"certificate", req.NamespacedName.String(),
+						"from", migrateFrom,
+						"class", aws2.Classify(err),
+						"error", err,
+					)
+					recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "MigrationFailed",
+						"Failed to migrate from %s: %v", migrateFrom, err)
+					if updateErr := r.updateSyncError(&certificate, err); updateErr != nil {
+						zap.S().Errorw("Error occurred updating certificate",
+							"certificate", req.NamespacedName.String(),
+							"error", updateErr,
+						)
+					}
+					return requeueForError(err)
+				}
+				resolvedAcmCertificate = migrated.Summary
+				resolvedAcmTags = migrated.Tags
+				region = migrated.Region
+				// Certificates migrated by cert-id come from the cache, which is keyed by that tag
+				cached = !strings.HasPrefix(migrateFrom, "arn:")
+				migratingFrom = previousId
+			} else if certificateArn := certificate.ObjectMeta.Annotations[certificateArnAnnotation]; certificateArn != "" {
+				adopted, err := r.adoptCertificate(certificateArn)
+				if err != nil {
+					zap.S().Errorw("Failed to adopt certificate",
+						"certificate", req.NamespacedName.String(),
+						"arn", certificateArn,
+						"class", aws2.Classify(err),
+						"error", err,
+					)
+					recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "AdoptFailed",
+						"Failed to adopt certificate %s: %s: %v", certificateArn, aws2.ErrorCode(err), err)
+					if updateErr := r.updateSyncError(&certificate, err); updateErr != nil {
+						zap.S().Errorw("Error occurred updating certificate",
+							"certificate", req.NamespacedName.String(),
+							"error", updateErr,
+						)
+					}
+					return requeueForError(err)
+				}
+				resolvedAcmCertificate = adopted.Summary
+				resolvedAcmTags = adopted.Tags
+				region = adopted.Region
 			}
 
-			var importCertificateInput = r.GetImportCertificateInput(certificate, resolvedAcmCertificate, resolvedAcmTags)
-			result, err := r.AcmService.UpsertCertificate(&importCertificateInput)
-			mutex.RUnlock()
+			if resolvedAcmCertificate != nil {
+				certificateArn := aws.StringValue(resolvedAcmCertificate.CertificateArn)
+				if err := checkOwnership(&certificate, req.NamespacedName.String(), migratingFrom, r.ClusterId, r.ClaimUntagged, certificateArn, resolvedAcmTags, cached); err != nil {
+					zap.S().Warnw("Refusing to reimport certificate owned by another Certificate",
+						"certificate", req.NamespacedName.String(),
+						"arn", certificateArn,
+						"reason", err.Error(),
+					)
+					recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "OwnershipConflict", "%v", err)
+					return ctrl.Result{}, r.updateSyncError(&certificate, err)
+				}
+			}
+
+			acmService, err := r.serviceFor(&certificate, region)
 			if err != nil {
-				zap.S().Error("Error occurred updating cert", zap.String("certificate", req.NamespacedName.String()), zap.Error(err))
 				return ctrl.Result{}, err
 			}
+			if resolvedAcmCertificate != nil && !r.ForceImport {
+				window, err := r.maintenanceWindowFor(&certificate)
+				if err != nil {
+					recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "InvalidMaintenanceWindow", "%v", err)
+					return ctrl.Result{}, r.updateSyncError(&certificate, err)
+				}
+				deferFor, err := deferReimport(window, r.MaintenanceWindowOverride, acmService, resolvedAcmCertificate.CertificateArn, time.Now())
+				if err != nil {
+					zap.S().Errorw("Error occurred describing certificate",
+						"certificate", req.NamespacedName.String(),
+						"arn", aws.StringValue(resolvedAcmCertificate.CertificateArn),
+						"class", aws2.Classify(err),
+						"error", err,
+					)
+					recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "DescribeFailed",
+						"Failed to describe certificate %s: %s: %v",
+						aws.StringValue(resolvedAcmCertificate.CertificateArn), aws2.ErrorCode(err), err)
+					if updateErr := r.updateSyncError(&certificate, err); updateErr != nil {
+						zap.S().Errorw("Error occurred updating certificate",
+							"certificate", req.NamespacedName.String(),
+							"error", updateErr,
+						)
+					}
+					return requeueForError(err)
+				}
+				if deferFor > 0 {
+					zap.S().Infow("Deferring reimport until the next maintenance window",
+						"certificate", req.NamespacedName.String(),
+						"arn", aws.StringValue(resolvedAcmCertificate.CertificateArn),
+						"after", deferFor.String(),
+					)
+					recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Deferred",
+						"Deferring reimport into %s until the next maintenance window at %s",
+						aws.StringValue(resolvedAcmCertificate.CertificateArn), time.Now().Add(deferFor).UTC().Format(time.RFC3339))
+					return requeueSooner(r.checkExpiry(&certificate, req.NamespacedName.String(), time.Now()), deferFor), nil
+				}
+			}
+
+			var importCertificateInput = r.GetImportCertificateInput(certificate, resolvedAcmCertificate, resolvedAcmTags, settings.tags)
+			importCertificateInput.Tags = mergeTags(importCertificateInput.Tags, clusterTags(r.ClusterId))
+			// Certificates whose NotBefore is in the future, as some issuers set it to allow for clock skew, are
+			// imported once they are valid rather than handed to ACM early
+			if leaf, err := (&Certificate{certificate: importCertificateInput.Certificate}).Leaf(); err == nil {
+				if waitFor := notYetValidFor(leaf, time.Now()); waitFor > 0 {
+					zap.S().Infow("Delaying import of certificate that is not yet valid",
+						"certificate", req.NamespacedName.String(),
+						"notBefore", leaf.NotBefore.UTC().Format(time.RFC3339),
+					)
+					recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "NotYetValid",
+						"Delaying import until the certificate is valid at %s", leaf.NotBefore.UTC().Format(time.RFC3339))
+					return requeueSooner(r.checkExpiry(&certificate, req.NamespacedName.String(), time.Now()), waitFor), nil
+				}
+			}
+			result, err := acmService.UpsertCertificate(&importCertificateInput)
+			if taggingErr, ok := err.(*aws2.TaggingError); ok {
+				// The certificate was reimported but still carries its previous tags, so it is
+				// reimported and tagged again on the next attempt
+				zap.S().Errorw("Failed to tag reimported certificate",
+					"certificate", req.NamespacedName.String(),
+					"arn", aws.StringValue(taggingErr.CertificateArn),
+					"class", aws2.Classify(err),
+					"error", taggingErr.Err,
+				)
+				recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "TaggingFailed",
+					"Failed to tag certificate %s: %s: %v", aws.StringValue(taggingErr.CertificateArn), aws2.ErrorCode(err), taggingErr.Err)
+				setUntagged(aws.StringValue(taggingErr.CertificateArn), true)
+				mutex.Lock()
+				if entry := r.Cache[req.NamespacedName.String()]; entry != nil {
+					entry.Untagged = true
+				}
+				mutex.Unlock()
+				if updateErr := r.updateSyncError(&certificate, err); updateErr != nil {
+					zap.S().Errorw("Error occurred updating certificate",
+						"certificate", req.NamespacedName.String(),
+						"error", updateErr,
+					)
+				}
+				return requeueForError(err)
+			}
+			if err != nil {
+				zap.S().Errorw("Error occurred importing certificate",
+					"certificate", req.NamespacedName.String(),
+					"class", aws2.Classify(err),
+					"error", err,
+				)
+				recordEvent(r.Recorder, &certificate, v1.EventTypeWarning, "ImportFailed",
+					"Failed to import certificate into ACM: %s: %v", aws2.ErrorCode(err), err)
+				if updateErr := r.updateSyncError(&certificate, err); updateErr != nil {
+					zap.S().Errorw("Error occurred updating certificate",
+						"certificate", req.NamespacedName.String(),
+						"error", updateErr,
+					)
+				}
+				return requeueForError(err)
+			}
+			revision := 0
+			if certificate.Status.Revision != nil {
+				revision = *certificate.Status.Revision
+			}
+			if r.dryRun(&certificate) {
+				if resolvedAcmCertificate == nil {
+					recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "DryRun",
+						"Would import revision %d into ACM with tags %s", revision, aws2.FormatTags(importCertificateInput.Tags))
+				} else {
+					recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "DryRun",
+						"Would reimport revision %d into %s with tags %s", revision,
+						aws.StringValue(result.CertificateArn), aws2.FormatTags(importCertificateInput.Tags))
+				}
+				return ctrl.Result{}, nil
+			}
+			if migratingFrom != "" {
+				zap.S().Infow("Migrated certificate",
+					"certificate", req.NamespacedName.String(),
+					"from", migratingFrom,
+					"arn", aws.StringValue(result.CertificateArn),
+				)
+				recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Migrated",
+					"Migrated certificate %s from %s", aws.StringValue(result.CertificateArn), migratingFrom)
+			} else if resolvedAcmCertificate != nil && !cached {
+				zap.S().Infow("Adopted certificate",
+					"certificate", req.NamespacedName.String(),
+					"arn", aws.StringValue(result.CertificateArn),
+				)
+				recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Adopted", "Adopted certificate %s", aws.StringValue(result.CertificateArn))
+			}
+			if resolvedAcmCertificate == nil {
+				recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Imported",
+					"Imported revision %d into ACM as %s", revision, aws.StringValue(result.CertificateArn))
+				importsTotal.WithLabelValues("Certificate").Inc()
+			} else {
+				recordEvent(r.Recorder, &certificate, v1.EventTypeNormal, "Reimported",
+					"Reimported revision %d into ACM as %s", revision, aws.StringValue(result.CertificateArn))
+				reimportsTotal.WithLabelValues("Certificate").Inc()
+			}
+			var notAfter *time.Time
+			leaf, err := (&Certificate{certificate: importCertificateInput.Certificate}).Leaf()
+			if err == nil {
+				notAfter = &leaf.NotAfter
+			} else {
+				leaf = nil
+			}
+			setUntagged(aws.StringValue(result.CertificateArn), false)
+			setSkipReason(req.NamespacedName.String(), "")
+			synced := &syncRecord{revision: revision, leaf: leaf, at: time.Now()}
+			recordSyncSuccess(&certificate, synced.revision, synced.leaf, synced.at)
+			statusChanged = true
 			mutex.Lock()
+			if migratingFrom != "" && migratingFrom != req.NamespacedName.String() {
+				delete(r.Cache, migratingFrom)
+				setCertificateNotAfter(migratingFrom, nil)
+				forgetExpiry(migratingFrom)
+			}
 			r.Cache[req.NamespacedName.String()] = &AcmCertificate{
 				Summary: &acm.CertificateSummary{
 					CertificateArn: result.CertificateArn,
 				},
-				Tags: result.Tags,
+				Tags:     result.Tags,
+				Region:   region,
+				NotAfter: notAfter,
+				synced:   synced,
 			}
 			mutex.Unlock()
+			setCertificateNotAfter(req.NamespacedName.String(), notAfter)
+			if migratingFrom != "" && migratingFrom != req.NamespacedName.String() {
+				if err := r.releasePrevious(migratingFrom); err != nil {
+					zap.S().Errorw("Failed to release previous certificate",
+						"certificate", req.NamespacedName.String(),
+						"previous", migratingFrom,
+						"error", err,
+					)
+				}
+			}
 		}
 
-		if r.AddMetadataIfNeeded(&certificate, req.NamespacedName.String()) {
+		if !r.dryRun(&certificate) && setAnnotation(&certificate, effectiveDeletionPolicyAnnotation, string(settings.deletionPolicy)) {
+			statusChanged = true
+		}
+		if r.AddMetadataIfNeeded(&certificate, req.NamespacedName.String()) || statusChanged {
 			if err := r.Update(context.Background(), &certificate); err != nil {
-				zap.S().Error("Error occurred updating cert", zap.String("certificate", req.NamespacedName.String()), zap.Error(err))
+				zap.S().Errorw("Error occurred updating certificate",
+					"certificate", req.NamespacedName.String(),
+					"error", err,
+				)
 				return reconcile.Result{}, err
 			}
 		}
+		result := r.checkExpiry(&certificate, req.NamespacedName.String(), time.Now())
+		// Reimports requested for a later time are picked up when they are due
+		if _, dueIn := reimportRequested(&certificate, time.Now()); dueIn > 0 {
+			result = requeueSooner(result, dueIn)
+		}
+		return result, nil
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// certificatesForPolicy maps an AcmImportPolicy to every Certificate, since any of them may match it
+func (r *CertificateReconciler) certificatesForPolicy(obj handler.MapObject) []reconcile.Request {
+	var certificates cmapiv1.CertificateList
+	if err := r.List(context.Background(), &certificates); err != nil {
+		zap.S().Errorw("Failed to list certificates", "error", err)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(certificates.Items))
+	for _, certificate := range certificates.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: certificate.Namespace,
+			Name:      certificate.Name,
+		}})
+	}
+	return requests
+}
+
 func (r *CertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.InitializeCache()
+	err := metrics.Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "acm_importer_cache_size",
+		Help: "Number of ACM certificates in the controller's cache",
+	}, func() float64 {
+		mutex.RLock()
+		defer mutex.RUnlock()
+		size := 0
+		for _, entry := range r.Cache {
+			if entry != nil {
+				size++
+			}
+		}
+		return float64(size)
+	}))
+	if err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&cmapiv1.Certificate{}).
+		Watches(&source.Kind{Type: &acmv1alpha1.AcmImportPolicy{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.certificatesForPolicy),
+		}).
 		WithOptions(controller.Options{MaxConcurrentReconciles: 5}).
 		Complete(r)
 }
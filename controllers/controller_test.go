@@ -1,38 +1,138 @@
 package controllers_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	aws2 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/acm"
 	"github.com/legalzoom/cert-manager-acm-importer/controllers"
 	"github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
 	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
 	cmmetav1 "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
 	"testing"
+	"time"
 )
 
 type MockService struct {
-	input *acm.ImportCertificateInput
+	input        *acm.ImportCertificateInput
+	certificates []*acm.CertificateSummary
+	tags         map[string][]*acm.Tag
+	deleted      []string
+	upsertErr    error
+	removedTags  []*acm.Tag
+	descriptions map[string]*acm.CertificateDetail
+	// returnTags returns the imported tags from UpsertCertificate, as the AWS service does after tagging
+	returnTags bool
+	// describeErr is returned from DescribeCertificate when set
+	describeErr error
+	// listTagsErr is returned from ListTagsForCertificate when set
+	listTagsErr error
+	// keyTypes are the key algorithms of certificates by ARN, RSA_2048 when unset. As in ACM, only
+	// certificates of the key types asked for, or RSA_2048 when none are, are listed.
+	keyTypes map[string]string
 }
 
 func (m *MockService) UpsertCertificate(input *acm.ImportCertificateInput) (*aws.UpsertCertificateResponse, error) {
 	arn := "test"
+	if input.CertificateArn != nil {
+		arn = *input.CertificateArn
+	}
 	m.input = input
+	if m.upsertErr != nil {
+		return nil, m.upsertErr
+	}
+	var tags []*acm.Tag
+	if m.returnTags {
+		tags = input.Tags
+	}
 	return &aws.UpsertCertificateResponse{
 		CertificateArn: &arn,
-		Tags:           nil,
+		Tags:           tags,
 	}, nil
 }
 
+// conflictingClient fails the given number of updates, as when cert-manager updated the Certificate first
+type conflictingClient struct {
+	client.Client
+	conflicts int
+}
+
+func (c *conflictingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return errors.New("the object has been modified")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
 func (m *MockService) DeleteCertificate(input *acm.DeleteCertificateInput) (*acm.DeleteCertificateOutput, error) {
+	m.deleted = append(m.deleted, *input.CertificateArn)
 	return nil, &acm.ResourceNotFoundException{}
 }
 
+func (m *MockService) DescribeCertificate(input *acm.DescribeCertificateInput) (*acm.DescribeCertificateOutput, error) {
+	if m.describeErr != nil {
+		return nil, m.describeErr
+	}
+	if detail, ok := m.descriptions[*input.CertificateArn]; ok {
+		return &acm.DescribeCertificateOutput{Certificate: detail}, nil
+	}
+	return &acm.DescribeCertificateOutput{
+		Certificate: &acm.CertificateDetail{
+			CertificateArn: input.CertificateArn,
+		},
+	}, nil
+}
+
+func (m *MockService) ListCertificates(input *acm.ListCertificatesInput) (*acm.ListCertificatesOutput, error) {
+	keyTypes := map[string]bool{acm.KeyAlgorithmRsa2048: true}
+	if input.Includes != nil && len(input.Includes.KeyTypes) > 0 {
+		keyTypes = map[string]bool{}
+		for _, keyType := range input.Includes.KeyTypes {
+			keyTypes[*keyType] = true
+		}
+	}
+	var certificates []*acm.CertificateSummary
+	for _, certificate := range m.certificates {
+		keyType, ok := m.keyTypes[*certificate.CertificateArn]
+		if !ok {
+			keyType = acm.KeyAlgorithmRsa2048
+		}
+		if keyTypes[keyType] {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return &acm.ListCertificatesOutput{
+		CertificateSummaryList: certificates,
+	}, nil
+}
+
+func (m *MockService) ListTagsForCertificate(input *acm.ListTagsForCertificateInput) (*acm.ListTagsForCertificateOutput, error) {
+	if m.listTagsErr != nil {
+		return nil, m.listTagsErr
+	}
+	return &acm.ListTagsForCertificateOutput{
+		Tags: m.tags[*input.CertificateArn],
+	}, nil
+}
+
+func (m *MockService) RemoveTagsFromCertificate(input *acm.RemoveTagsFromCertificateInput) (*acm.RemoveTagsFromCertificateOutput, error) {
+	m.removedTags = append(m.removedTags, input.Tags...)
+	return &acm.RemoveTagsFromCertificateOutput{}, nil
+}
+
 func hasTag(key string, value string, tags []*acm.Tag) bool {
 	for _, tag := range tags {
 		if *tag.Key == key && *tag.Value == value {
@@ -296,3 +396,1893 @@ func TestDelete(t *testing.T) {
 	}
 
 }
+
+func TestImportSelectedByPolicy(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: map[string]string{
+				"expose": "alb",
+			},
+			Annotations: map[string]string{
+				"legalzoom.com/acm-tags": "legalzoom.com/cert-importer/cert-revision=999",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "foo",
+			Labels: map[string]string{
+				"env": "prod",
+			},
+		},
+	}
+
+	policy := &acmv1alpha1.AcmImportPolicy{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "prod",
+		},
+		Spec: acmv1alpha1.AcmImportPolicySpec{
+			NamespaceSelector: &v1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			Selector:          &v1.LabelSelector{MatchLabels: map[string]string{"expose": "alb"}},
+			Tags: map[string]string{
+				"team":                                "platform",
+				"legalzoom.com/cert-importer/cert-id": "victim/cert",
+			},
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	acmv1alpha1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret, namespace, policy)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+
+	controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}})
+
+	if mockService.input == nil {
+		t.Fatal("Certificate selected by policy was not imported")
+	}
+	if !hasTag("team", "platform", mockService.input.Tags) {
+		t.Error("Missing policy tag")
+	}
+	if !hasTag("legalzoom.com/cert-importer/cert-id", "foo/bar", mockService.input.Tags) ||
+		!hasTag("legalzoom.com/cert-importer/cert-revision", "1", mockService.input.Tags) ||
+		len(mockService.input.Tags) != 3 {
+		t.Errorf("Expected reserved tags from the policy and annotations to be ignored, got %v", mockService.input.Tags)
+	}
+
+	namespace.Labels["env"] = "staging"
+	client = fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret, namespace, policy)
+	mockService = &MockService{}
+	controller.Client = client
+	controller.APIReader = client
+	controller.AcmService = mockService
+	controller.Cache = make(map[string]*controllers.AcmCertificate)
+
+	controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}})
+
+	if mockService.input != nil {
+		t.Error("Certificate outside the policy's namespaces was imported")
+	}
+}
+
+func TestInvalidPolicySelector(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Name:       "bar",
+			Namespace:  "foo",
+			Finalizers: []string{"certificate.legalzoom.com"},
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+	}
+	namespace := &corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "foo",
+		},
+	}
+	policy := &acmv1alpha1.AcmImportPolicy{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "typo",
+		},
+		Spec: acmv1alpha1.AcmImportPolicySpec{
+			Selector: &v1.LabelSelector{MatchExpressions: []v1.LabelSelectorRequirement{
+				{Key: "expose", Operator: "Inn", Values: []string{"alb"}},
+			}},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	acmv1alpha1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, namespace, policy)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{CertificateArn: aws2.String("arn1")},
+	}
+
+	_, err := controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}})
+	if err == nil {
+		t.Error("Expected the invalid selector to be returned as an error")
+	}
+	if len(mockService.deleted) != 0 {
+		t.Errorf("Expected no certificates to be deleted, deleted %v", mockService.deleted)
+	}
+}
+
+func TestImportSelectedByIssuer(t *testing.T) {
+	cases := []struct {
+		kind     string
+		imported bool
+	}{
+		{"", true},
+		{"Issuer", true},
+		{"ClusterIssuer", false},
+	}
+
+	for _, c := range cases {
+		// An empty kind on the Certificate refers to an Issuer
+		basicCert := cmapiv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "bar",
+				Namespace: "foo",
+			},
+			Spec: cmapiv1.CertificateSpec{
+				SecretName: "secret",
+				IssuerRef: cmmetav1.ObjectReference{
+					Name: "letsencrypt",
+				},
+			},
+			Status: cmapiv1.CertificateStatus{
+				Revision: aws2.Int(1),
+				Conditions: []cmapiv1.CertificateCondition{
+					{
+						Type:   cmapiv1.CertificateConditionReady,
+						Status: cmmetav1.ConditionTrue,
+					},
+				},
+			},
+		}
+
+		policy := &acmv1alpha1.AcmImportPolicy{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "letsencrypt",
+			},
+			Spec: acmv1alpha1.AcmImportPolicySpec{
+				IssuerRefs: []acmv1alpha1.IssuerReference{
+					{Name: "letsencrypt", Kind: c.kind},
+				},
+			},
+		}
+
+		namespace := &corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "foo"}}
+
+		basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		cmapiv1.AddToScheme(scheme)
+		acmv1alpha1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret, namespace, policy)
+		mockService := &MockService{}
+		controller := controllers.CertificateReconciler{
+			Client:     client,
+			Cache:      make(map[string]*controllers.AcmCertificate),
+			AcmService: mockService,
+			APIReader:  client,
+		}
+
+		controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "bar",
+		}})
+
+		if imported := mockService.input != nil; imported != c.imported {
+			t.Errorf("Policy for kind %q: expected imported to be %v", c.kind, c.imported)
+		}
+	}
+}
+
+func TestImportEvents(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(365*24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: &MockService{returnTags: true},
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{
+				Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+				Value: aws2.String("1"),
+			},
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	controller.Reconcile(request)
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Reimported Reimported revision 2") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	controller.Reconcile(request)
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Skipped") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	controller.Reconcile(request)
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected the unchanged skip reason not to be recorded again, got %q", <-recorder.Events)
+	}
+}
+
+func TestImportStatusAnnotations(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	notAfter := time.Now().Add(24 * time.Hour)
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), notAfter, "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	controller.Reconcile(request)
+
+	var certificate cmapiv1.Certificate
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	if certificate.Annotations["legalzoom.com/acm-imported-revision"] != "2" {
+		t.Errorf("Incorrect imported revision %q", certificate.Annotations["legalzoom.com/acm-imported-revision"])
+	}
+	if certificate.Annotations["legalzoom.com/acm-last-sync-time"] == "" {
+		t.Error("Missing last sync time")
+	}
+	if certificate.Annotations["legalzoom.com/acm-fingerprint"] == "" {
+		t.Error("Missing fingerprint")
+	}
+	if certificate.Annotations["legalzoom.com/acm-not-after"] != notAfter.UTC().Format(time.RFC3339) {
+		t.Errorf("Incorrect not after %q", certificate.Annotations["legalzoom.com/acm-not-after"])
+	}
+	if _, ok := certificate.Annotations["legalzoom.com/acm-last-error"]; ok {
+		t.Error("Unexpected last error")
+	}
+}
+
+func TestImportStatusAnnotationsUpdateConflict(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm":             "true",
+				"legalzoom.com/acm-reimport-requested-at": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	mockService := &MockService{returnTags: true}
+	controller := controllers.CertificateReconciler{
+		Client:     &conflictingClient{Client: client, conflicts: 1},
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	if _, err := controller.Reconcile(request); err == nil {
+		t.Fatal("Expected the conflicting update to be returned")
+	}
+	if mockService.input == nil {
+		t.Fatal("Certificate was not imported")
+	}
+
+	// The sync is recorded on the next reconcile without importing, or handling the request, again
+	mockService.input = nil
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+	if mockService.input != nil {
+		t.Error("Expected no second import")
+	}
+	var certificate cmapiv1.Certificate
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	if certificate.Annotations["legalzoom.com/acm-imported-revision"] != "2" || certificate.Annotations["legalzoom.com/acm-last-sync-time"] == "" {
+		t.Errorf("Expected the sync to be recorded, got %v", certificate.Annotations)
+	}
+}
+
+func TestAdoptAndMigrateThrottled(t *testing.T) {
+	certificateArn := "arn:aws:acm:us-east-1:123456789012:certificate/existing"
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		reason      string
+	}{
+		{"adopt", map[string]string{
+			"legalzoom.com/certificate-arn": certificateArn,
+			"legalzoom.com/acm-adopt":       "true",
+		}, "Warning AdoptFailed"},
+		{"migrate", map[string]string{
+			"legalzoom.com/acm-migrate-from": certificateArn,
+		}, "Warning MigrationFailed"},
+	}
+
+	for _, c := range cases {
+		annotations := map[string]string{"legalzoom.com/import-to-acm": "true"}
+		for key, value := range c.annotations {
+			annotations[key] = value
+		}
+		basicCert := cmapiv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: annotations,
+				Name:        "throttled-" + c.name,
+				Namespace:   "foo",
+			},
+			Spec: cmapiv1.CertificateSpec{
+				SecretName: "secret",
+			},
+			Status: cmapiv1.CertificateStatus{
+				Revision: aws2.Int(1),
+				Conditions: []cmapiv1.CertificateCondition{
+					{
+						Type:   cmapiv1.CertificateConditionReady,
+						Status: cmmetav1.ConditionTrue,
+					},
+				},
+			},
+		}
+
+		basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(90*24*time.Hour), "example.com")
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		cmapiv1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+		mockService := &MockService{listTagsErr: awserr.New("ThrottlingException", "Rate exceeded", nil)}
+		recorder := record.NewFakeRecorder(10)
+		controller := controllers.CertificateReconciler{
+			Client:     client,
+			Cache:      make(map[string]*controllers.AcmCertificate),
+			AcmService: mockService,
+			APIReader:  client,
+			Recorder:   recorder,
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "throttled-" + c.name,
+		}}
+		result, err := controller.Reconcile(request)
+		if err != nil {
+			t.Errorf("%s: expected the throttled request to be requeued without an error, got %v", c.name, err)
+		}
+		if result.RequeueAfter < time.Minute {
+			t.Errorf("%s: expected a requeue after backing off, got %v", c.name, result.RequeueAfter)
+		}
+		if len(recorder.Events) != 1 || !strings.HasPrefix(<-recorder.Events, c.reason) {
+			t.Errorf("%s: expected a %s event", c.name, c.reason)
+		}
+		if mockService.input != nil {
+			t.Errorf("%s: expected nothing to be imported", c.name)
+		}
+	}
+}
+
+func TestImportAdoptsCertificateArn(t *testing.T) {
+	certificateArn := "arn:aws:acm:us-east-1:123456789012:certificate/adopted"
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm":   "true",
+				"legalzoom.com/certificate-arn": certificateArn,
+				"legalzoom.com/acm-adopt":       "true",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	mockService := &MockService{
+		tags: map[string][]*acm.Tag{
+			certificateArn: {
+				{Key: aws2.String("team"), Value: aws2.String("a")},
+			},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+
+	// The certificate is only reported as adopted once the import succeeded
+	mockService.upsertErr = errors.New("connection reset")
+	controller.Reconcile(request)
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning ImportFailed") || len(recorder.Events) != 0 {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	mockService.upsertErr = nil
+	controller.Reconcile(request)
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Adopted Adopted certificate "+certificateArn) {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	if mockService.input == nil {
+		t.Fatal("Certificate was not imported")
+	}
+	if aws2.StringValue(mockService.input.CertificateArn) != certificateArn {
+		t.Errorf("Imported into %q instead of the adopted certificate", aws2.StringValue(mockService.input.CertificateArn))
+	}
+	if !hasTag("legalzoom.com/cert-importer/cert-id", "foo/bar", mockService.input.Tags) {
+		t.Error("Incorrect cert-id tag")
+	}
+	if !hasTag("team", "a", mockService.input.Tags) {
+		t.Error("Existing tags of the adopted certificate were not kept")
+	}
+	if entry := controller.Cache["foo/bar"]; entry == nil || aws2.StringValue(entry.Summary.CertificateArn) != certificateArn {
+		t.Error("Adopted certificate was not cached")
+	}
+}
+
+func TestImportOwnershipConflict(t *testing.T) {
+	certificateArn := "arn:aws:acm:us-east-1:123456789012:certificate/other"
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm":   "true",
+				"legalzoom.com/certificate-arn": certificateArn,
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	mockService := &MockService{
+		tags: map[string][]*acm.Tag{
+			certificateArn: {
+				{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String("other/certificate")},
+			},
+		},
+	}
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+
+	certIdTag := func(value string) *acm.Tag {
+		return &acm.Tag{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String(value)}
+	}
+	clusterIdTag := func(value string) *acm.Tag {
+		return &acm.Tag{Key: aws2.String("legalzoom.com/cert-importer/cluster-id"), Value: aws2.String(value)}
+	}
+	adopt := map[string]string{"legalzoom.com/acm-adopt": "true"}
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		tags        []*acm.Tag
+		imported    bool
+	}{
+		{"owned by another certificate", nil, []*acm.Tag{certIdTag("other/certificate"), clusterIdTag("prod")}, false},
+		{"adoption from another namespace", adopt, []*acm.Tag{certIdTag("other/certificate"), clusterIdTag("prod")}, false},
+		{"adoption from another cluster", adopt, []*acm.Tag{certIdTag("foo/old"), clusterIdTag("staging")}, false},
+		{"adoption within the namespace", adopt, []*acm.Tag{certIdTag("foo/old"), clusterIdTag("prod")}, true},
+		{"adoption of an untagged certificate", adopt, nil, true},
+	}
+
+	for _, c := range cases {
+		cert := basicCert.DeepCopy()
+		for key, value := range c.annotations {
+			cert.Annotations[key] = value
+		}
+		client := fake.NewFakeClientWithScheme(scheme, cert, basicSecret.DeepCopy())
+		recorder := record.NewFakeRecorder(10)
+		mockService.input = nil
+		mockService.tags[certificateArn] = c.tags
+		controller := controllers.CertificateReconciler{
+			Client:     client,
+			Cache:      make(map[string]*controllers.AcmCertificate),
+			AcmService: mockService,
+			APIReader:  client,
+			Recorder:   recorder,
+			ClusterId:  "prod",
+		}
+
+		controller.Reconcile(request)
+
+		if c.imported && mockService.input == nil {
+			t.Errorf("%s: expected certificate to be imported", c.name)
+		}
+		if !c.imported {
+			if mockService.input != nil {
+				t.Errorf("%s: expected import to be refused", c.name)
+			}
+			if event := <-recorder.Events; !strings.HasPrefix(event, "Warning OwnershipConflict") {
+				t.Errorf("%s: unexpected event %q", c.name, event)
+			}
+		}
+	}
+}
+
+func TestInitializeCacheClusterId(t *testing.T) {
+	certIdTag := func(value string) *acm.Tag {
+		return &acm.Tag{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String(value)}
+	}
+	clusterIdTag := func(value string) *acm.Tag {
+		return &acm.Tag{Key: aws2.String("legalzoom.com/cert-importer/cluster-id"), Value: aws2.String(value)}
+	}
+	mockService := &MockService{
+		certificates: []*acm.CertificateSummary{
+			{CertificateArn: aws2.String("staging")},
+			{CertificateArn: aws2.String("prod")},
+			{CertificateArn: aws2.String("legacy")},
+		},
+		tags: map[string][]*acm.Tag{
+			"staging": {certIdTag("ingress/wildcard"), clusterIdTag("staging")},
+			"prod":    {certIdTag("ingress/wildcard"), clusterIdTag("prod")},
+			"legacy":  {certIdTag("ingress/legacy")},
+		},
+	}
+	cases := []struct {
+		clusterId     string
+		claimUntagged bool
+		arn           string
+		legacy        bool
+	}{
+		{"prod", false, "prod", false},
+		{"staging", false, "staging", false},
+		{"prod", true, "prod", true},
+		{"", false, "", true},
+	}
+
+	for _, c := range cases {
+		controller := controllers.CertificateReconciler{
+			Cache:         make(map[string]*controllers.AcmCertificate),
+			AcmService:    mockService,
+			ClusterId:     c.clusterId,
+			ClaimUntagged: c.claimUntagged,
+		}
+
+		controller.InitializeCache()
+
+		arn := ""
+		if entry := controller.Cache["ingress/wildcard"]; entry != nil {
+			arn = *entry.Summary.CertificateArn
+		}
+		if arn != c.arn {
+			t.Errorf("Cluster %q: expected %q to be cached, got %q", c.clusterId, c.arn, arn)
+		}
+		if legacy := controller.Cache["ingress/legacy"] != nil; legacy != c.legacy {
+			t.Errorf("Cluster %q claiming untagged %v: expected the certificate without a cluster ID cached to be %v",
+				c.clusterId, c.claimUntagged, c.legacy)
+		}
+	}
+}
+
+func TestInitializeCacheDuplicates(t *testing.T) {
+	tags := func(certId string, revision string) []*acm.Tag {
+		return []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String(certId)},
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-revision"), Value: aws2.String(revision)},
+		}
+	}
+	annotatedCert := &cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/certificate-arn": "annotated-old",
+			},
+			Name:      "annotated",
+			Namespace: "foo",
+		},
+	}
+	scheme := runtime.NewScheme()
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, annotatedCert)
+	mockService := &MockService{
+		certificates: []*acm.CertificateSummary{
+			{CertificateArn: aws2.String("revision-new")},
+			{CertificateArn: aws2.String("revision-old")},
+			{CertificateArn: aws2.String("annotated-new")},
+			{CertificateArn: aws2.String("annotated-old")},
+		},
+		tags: map[string][]*acm.Tag{
+			"revision-new":  tags("foo/revision", "3"),
+			"revision-old":  tags("foo/revision", "2"),
+			"annotated-new": tags("foo/annotated", "3"),
+			"annotated-old": tags("foo/annotated", "2"),
+		},
+		keyTypes: map[string]string{
+			"revision-new": acm.KeyAlgorithmEcPrime256v1,
+		},
+	}
+	controller := controllers.CertificateReconciler{
+		Cache:            make(map[string]*controllers.AcmCertificate),
+		AcmService:       mockService,
+		APIReader:        client,
+		DeleteDuplicates: true,
+	}
+
+	controller.InitializeCache()
+
+	if arn := *controller.Cache["foo/revision"].Summary.CertificateArn; arn != "revision-new" {
+		t.Errorf("Expected the highest revision to be kept, kept %s", arn)
+	}
+	if arn := *controller.Cache["foo/annotated"].Summary.CertificateArn; arn != "annotated-old" {
+		t.Errorf("Expected the annotated certificate to be kept, kept %s", arn)
+	}
+	if len(mockService.deleted) != 2 || mockService.deleted[0] != "revision-old" || mockService.deleted[1] != "annotated-new" {
+		t.Errorf("Unexpected duplicates deleted %v", mockService.deleted)
+	}
+}
+
+func TestImportTaggingFailed(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	mockService := &MockService{
+		upsertErr: &aws.TaggingError{CertificateArn: aws2.String("test"), Err: errors.New("throttled")},
+	}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{
+				Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+				Value: aws2.String("1"),
+			},
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	if _, err := controller.Reconcile(request); err == nil {
+		t.Error("Expected the tagging error to be returned")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning TaggingFailed") {
+		t.Errorf("Unexpected event %q", event)
+	}
+	if !controller.Cache["foo/bar"].Untagged {
+		t.Error("Expected the cached certificate to be marked untagged")
+	}
+
+	restarted := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      map[string]*controllers.AcmCertificate{"foo/bar": {Summary: &acm.CertificateSummary{CertificateArn: aws2.String("test")}}},
+		AcmService: mockService,
+		APIReader:  client,
+	}
+	if untagged, err := restarted.Untagged(); err != nil || len(untagged) != 1 || untagged[0] != "foo/bar" {
+		t.Errorf("Expected the untagged certificate to be found after a restart, found %v, %v", untagged, err)
+	}
+
+	mockService.upsertErr = nil
+	controller.Reconcile(request)
+	if mockService.input == nil || !hasTag("legalzoom.com/cert-importer/cert-revision", "2", mockService.input.Tags) {
+		t.Error("Expected the certificate to be reimported and tagged again")
+	}
+	if untagged, err := controller.Untagged(); err != nil || len(untagged) != 0 {
+		t.Errorf("Expected no untagged certificates after tagging, found %v, %v", untagged, err)
+	}
+	var updated cmapiv1.Certificate
+	client.Get(context.TODO(), request.NamespacedName, &updated)
+	if _, ok := updated.Annotations["legalzoom.com/acm-untagged"]; ok {
+		t.Error("Expected the untagged annotation to be removed")
+	}
+}
+
+func TestUnmanage(t *testing.T) {
+	cases := []struct {
+		name           string
+		deletionPolicy string
+		recorded       string
+		deleted        bool
+	}{
+		{"delete", "", "", true},
+		{"retain", "Retain", "", false},
+		{"retained by a policy that no longer matches", "", "Retain", false},
+		{"override of the recorded policy", "Delete", "Retain", true},
+	}
+
+	for _, c := range cases {
+		basicCert := cmapiv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					"legalzoom.com/import-to-acm":                 "false",
+					"legalzoom.com/certificate-arn":               "test",
+					"legalzoom.com/acm-deletion-policy":           c.deletionPolicy,
+					"legalzoom.com/acm-effective-deletion-policy": c.recorded,
+					"legalzoom.com/acm-last-sync-time":            "2020-01-01T00:00:00Z",
+					"legalzoom.com/acm-imported-revision":         "1",
+				},
+				Name:       "bar",
+				Namespace:  "foo",
+				Finalizers: []string{"certificate.legalzoom.com"},
+			},
+		}
+		scheme := runtime.NewScheme()
+		cmapiv1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, &basicCert)
+		mockService := &MockService{}
+		controller := controllers.CertificateReconciler{
+			Client:     client,
+			Cache:      make(map[string]*controllers.AcmCertificate),
+			AcmService: mockService,
+			APIReader:  client,
+		}
+		controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+			Summary: &acm.CertificateSummary{
+				CertificateArn: aws2.String("test"),
+			},
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "bar",
+		}}
+		if _, err := controller.Reconcile(request); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if c.deleted && len(mockService.deleted) != 1 {
+			t.Errorf("%s: expected the ACM certificate to be deleted", c.name)
+		}
+		if !c.deleted && (len(mockService.deleted) != 0 || !hasTagKey("legalzoom.com/cert-importer/cert-id", mockService.removedTags)) {
+			t.Errorf("%s: expected the ACM certificate to be released", c.name)
+		}
+		if controller.Cache["foo/bar"] != nil {
+			t.Errorf("%s: expected the cache entry to be removed", c.name)
+		}
+
+		var certificate cmapiv1.Certificate
+		if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+			t.Fatal(err)
+		}
+		if len(certificate.Finalizers) != 0 {
+			t.Errorf("%s: expected the finalizer to be removed", c.name)
+		}
+		if _, ok := certificate.Annotations["legalzoom.com/certificate-arn"]; ok {
+			t.Errorf("%s: expected the ARN annotation to be removed", c.name)
+		}
+		if _, ok := certificate.Annotations["legalzoom.com/acm-last-sync-time"]; ok {
+			t.Errorf("%s: expected the sync status annotations to be removed", c.name)
+		}
+	}
+}
+
+func TestDeleteRetainReleasesCertificate(t *testing.T) {
+	now := v1.Now()
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm":       "true",
+				"legalzoom.com/acm-deletion-policy": "Retain",
+			},
+			DeletionTimestamp: &now,
+			Finalizers:        []string{"certificate.legalzoom.com"},
+			Name:              "bar",
+			Namespace:         "foo",
+		},
+	}
+	scheme := runtime.NewScheme()
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+	}
+
+	if _, err := controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mockService.deleted) != 0 {
+		t.Error("Expected the retained certificate not to be deleted")
+	}
+	for _, key := range []string{
+		"legalzoom.com/cert-importer/cert-id",
+		"legalzoom.com/cert-importer/cert-revision",
+		"legalzoom.com/cert-importer/cluster-id",
+	} {
+		if !hasTagKey(key, mockService.removedTags) {
+			t.Errorf("Expected the %s tag to be removed", key)
+		}
+	}
+}
+
+func TestImportRecordsDeletionPolicy(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Labels: map[string]string{
+				"team": "platform",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+		},
+	}
+	namespace := corev1.Namespace{ObjectMeta: v1.ObjectMeta{Name: "foo"}}
+	policy := acmv1alpha1.AcmImportPolicy{
+		ObjectMeta: v1.ObjectMeta{Name: "retain"},
+		Spec: acmv1alpha1.AcmImportPolicySpec{
+			Selector:       &v1.LabelSelector{MatchLabels: map[string]string{"team": "platform"}},
+			DeletionPolicy: acmv1alpha1.DeletionPolicyRetain,
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	acmv1alpha1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, &namespace, &policy)
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: &MockService{},
+		APIReader:  client,
+	}
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-revision"), Value: aws2.String("1")},
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+	var certificate cmapiv1.Certificate
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	if certificate.Annotations["legalzoom.com/acm-effective-deletion-policy"] != "Retain" {
+		t.Errorf("Expected the policy's deletion policy to be recorded, annotations %v", certificate.Annotations)
+	}
+}
+
+func hasTagKey(key string, tags []*acm.Tag) bool {
+	for _, tag := range tags {
+		if *tag.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestImportMigratesFromPreviousCertificate(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm":    "true",
+				"legalzoom.com/acm-migrate-from": "foo/old",
+			},
+			Name:      "new",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	previousCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:       "old",
+			Namespace:  "foo",
+			Finalizers: []string{"certificate.legalzoom.com"},
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, &previousCert, basicSecret)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+	controller.Cache["foo/old"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("attached"),
+		},
+		Tags: []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String("foo/old")},
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-revision"), Value: aws2.String("4")},
+		},
+	}
+
+	controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "new",
+	}})
+
+	if mockService.input == nil || aws2.StringValue(mockService.input.CertificateArn) != "attached" {
+		t.Fatal("Expected the certificate to be reimported into the previous ARN")
+	}
+	if !hasTag("legalzoom.com/cert-importer/cert-id", "foo/new", mockService.input.Tags) {
+		t.Error("Expected the cert-id tag to be rekeyed")
+	}
+	if _, ok := controller.Cache["foo/old"]; ok {
+		t.Error("Expected the previous cache entry to be removed")
+	}
+	if entry := controller.Cache["foo/new"]; entry == nil || aws2.StringValue(entry.Summary.CertificateArn) != "attached" {
+		t.Error("Expected the cache to be rekeyed")
+	}
+
+	var previous cmapiv1.Certificate
+	if err := client.Get(context.Background(), types.NamespacedName{Namespace: "foo", Name: "old"}, &previous); err != nil {
+		t.Fatal(err)
+	}
+	if previous.Annotations["legalzoom.com/import-to-acm"] != "false" || previous.Annotations["legalzoom.com/acm-deletion-policy"] != "Retain" {
+		t.Errorf("Expected the previous certificate to be released, annotations %v", previous.Annotations)
+	}
+}
+
+func TestImportRefusesCrossNamespaceMigration(t *testing.T) {
+	cases := []struct {
+		name        string
+		migrateFrom string
+	}{
+		{"cert-id", "victim/old"},
+		{"arn", "arn:aws:acm:us-east-1:123456789012:certificate/attached"},
+	}
+
+	for _, c := range cases {
+		basicCert := cmapiv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					"legalzoom.com/import-to-acm":    "true",
+					"legalzoom.com/acm-migrate-from": c.migrateFrom,
+				},
+				Name:      "new",
+				Namespace: "foo",
+			},
+			Spec: cmapiv1.CertificateSpec{
+				SecretName: "secret",
+			},
+			Status: cmapiv1.CertificateStatus{
+				Revision: aws2.Int(1),
+				Conditions: []cmapiv1.CertificateCondition{
+					{
+						Type:   cmapiv1.CertificateConditionReady,
+						Status: cmmetav1.ConditionTrue,
+					},
+				},
+			},
+		}
+
+		victimCert := cmapiv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					"legalzoom.com/import-to-acm": "true",
+				},
+				Name:       "old",
+				Namespace:  "victim",
+				Finalizers: []string{"certificate.legalzoom.com"},
+			},
+		}
+
+		victimTags := []*acm.Tag{
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String("victim/old")},
+			{Key: aws2.String("legalzoom.com/cert-importer/cert-revision"), Value: aws2.String("4")},
+		}
+		basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		cmapiv1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, &basicCert, &victimCert, basicSecret)
+		recorder := record.NewFakeRecorder(10)
+		mockService := &MockService{
+			tags: map[string][]*acm.Tag{
+				"arn:aws:acm:us-east-1:123456789012:certificate/attached": victimTags,
+			},
+		}
+		controller := controllers.CertificateReconciler{
+			Client:     client,
+			Cache:      make(map[string]*controllers.AcmCertificate),
+			AcmService: mockService,
+			APIReader:  client,
+			Recorder:   recorder,
+		}
+		controller.Cache["victim/old"] = &controllers.AcmCertificate{
+			Summary: &acm.CertificateSummary{
+				CertificateArn: aws2.String("arn:aws:acm:us-east-1:123456789012:certificate/attached"),
+			},
+			Tags: victimTags,
+		}
+
+		controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "new",
+		}})
+
+		if mockService.input != nil {
+			t.Errorf("%s: expected no import into another namespace's certificate", c.name)
+		}
+		if event := <-recorder.Events; !strings.HasPrefix(event, "Warning MigrationFailed") {
+			t.Errorf("%s: unexpected event %q", c.name, event)
+		}
+		if controller.Cache["victim/old"] == nil {
+			t.Errorf("%s: expected the victim's cache entry to be kept", c.name)
+		}
+		var victim cmapiv1.Certificate
+		if err := client.Get(context.Background(), types.NamespacedName{Namespace: "victim", Name: "old"}, &victim); err != nil {
+			t.Fatal(err)
+		}
+		if victim.Annotations["legalzoom.com/import-to-acm"] != "true" {
+			t.Errorf("%s: expected the victim certificate to stay managed, annotations %v", c.name, victim.Annotations)
+		}
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+				"legalzoom.com/acm-dry-run":   "true",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	controller.Reconcile(request)
+
+	if mockService.input != nil {
+		t.Error("Expected no certificate to be imported")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal DryRun Would import revision 1") {
+		t.Errorf("Unexpected event %q", event)
+	}
+	if controller.Cache["foo/bar"] != nil {
+		t.Error("Expected the cache to be unchanged")
+	}
+
+	var certificate cmapiv1.Certificate
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	if len(certificate.Finalizers) != 0 || certificate.Annotations["legalzoom.com/certificate-arn"] != "" {
+		t.Error("Expected the certificate to be unchanged")
+	}
+}
+
+func TestOrphans(t *testing.T) {
+	managedCert := &cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:      "managed",
+			Namespace: "foo",
+		},
+	}
+	unmanagedCert := &cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "false",
+			},
+			Name:      "unmanaged",
+			Namespace: "foo",
+		},
+	}
+	scheme := runtime.NewScheme()
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, managedCert, unmanagedCert)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+	for _, certId := range []string{"foo/managed", "foo/unmanaged", "foo/deleted"} {
+		controller.Cache[certId] = &controllers.AcmCertificate{
+			Summary: &acm.CertificateSummary{CertificateArn: aws2.String(certId)},
+		}
+	}
+
+	orphans, err := controller.Orphans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 2 || orphans[0].CertId != "foo/deleted" || orphans[1].CertId != "foo/unmanaged" {
+		t.Fatalf("Unexpected orphans %v", orphans)
+	}
+
+	if err := controller.DeleteOrphan(orphans[0]); err != nil {
+		t.Fatal(err)
+	}
+	if len(mockService.deleted) != 1 || mockService.deleted[0] != "foo/deleted" {
+		t.Errorf("Unexpected certificates deleted %v", mockService.deleted)
+	}
+	if _, ok := controller.Cache["foo/deleted"]; ok {
+		t.Error("Expected the orphan to be removed from the cache")
+	}
+}
+
+func TestReimportRequested(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm":             "true",
+				"legalzoom.com/acm-reimport-requested-at": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	mockService := &MockService{returnTags: true}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{
+				Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+				Value: aws2.String("2"),
+			},
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	controller.Reconcile(request)
+	if mockService.input == nil {
+		t.Fatal("Expected the requested reimport to happen although ACM holds the current revision")
+	}
+
+	var certificate cmapiv1.Certificate
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	if certificate.Annotations["legalzoom.com/acm-last-sync-time"] == "" {
+		t.Error("Expected the sync handling the reimport to be recorded")
+	}
+
+	mockService.input = nil
+	controller.Reconcile(request)
+	if mockService.input != nil {
+		t.Error("Expected the handled reimport not to be repeated")
+	}
+
+	// A reimport requested for a later time is requeued for that time
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	certificate.Annotations["legalzoom.com/acm-reimport-requested-at"] = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if err := client.Update(context.Background(), &certificate); err != nil {
+		t.Fatal(err)
+	}
+	result, err := controller.Reconcile(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockService.input != nil {
+		t.Error("Expected the reimport not to happen before the requested time")
+	}
+	if result.RequeueAfter <= 59*time.Minute || result.RequeueAfter > time.Hour {
+		t.Errorf("Expected a requeue at the requested time, got %v", result.RequeueAfter)
+	}
+}
+
+func TestPaused(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+				"legalzoom.com/acm-paused":    "true",
+			},
+			Name:       "paused",
+			Namespace:  "foo",
+			Finalizers: []string{"certificate.legalzoom.com"},
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+	controller.Cache["foo/paused"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{
+				Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+				Value: aws2.String("1"),
+			},
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "paused",
+	}}
+	controller.Reconcile(request)
+	if mockService.input != nil {
+		t.Error("Expected no import while paused")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Paused") {
+		t.Errorf("Unexpected event %q", event)
+	}
+	controller.Reconcile(request)
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected the Paused event only once, got %q", <-recorder.Events)
+	}
+	if value, _ := gaugeValue(t, "acm_importer_paused_certificates", nil); value != 1 {
+		t.Errorf("Expected one paused certificate, got %v", value)
+	}
+	if err := controller.Import("foo", "paused"); err != controllers.ErrPaused {
+		t.Errorf("Expected the import command to report the certificate paused, got %v", err)
+	}
+	if errs, err := controller.SyncAll(); err != nil || errs["foo/paused"] != controllers.ErrPaused {
+		t.Errorf("Expected the sync command to report the certificate paused, got %v, %v", errs, err)
+	}
+	if mockService.input != nil {
+		t.Error("Expected no import by the commands while paused")
+	}
+
+	var certificate cmapiv1.Certificate
+	if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+		t.Fatal(err)
+	}
+	delete(certificate.Annotations, "legalzoom.com/acm-paused")
+	if err := client.Update(context.Background(), &certificate); err != nil {
+		t.Fatal(err)
+	}
+	controller.Reconcile(request)
+	if mockService.input == nil || !hasTag("legalzoom.com/cert-importer/cert-revision", "2", mockService.input.Tags) {
+		t.Error("Expected the latest revision to be imported once unpaused")
+	}
+	if value, _ := gaugeValue(t, "acm_importer_paused_certificates", nil); value != 0 {
+		t.Errorf("Expected no paused certificates, got %v", value)
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	windowStart := time.Now().UTC().Add(2 * time.Hour)
+	basicNamespace := corev1.Namespace{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "foo",
+			Annotations: map[string]string{
+				"legalzoom.com/acm-maintenance-window": fmt.Sprintf("CRON_TZ=UTC 0 %d * * * 1h", windowStart.Hour()),
+			},
+		},
+	}
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:       "windowed",
+			Namespace:  "foo",
+			Finalizers: []string{"certificate.legalzoom.com"},
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(2),
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicNamespace, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	mockService := &MockService{
+		descriptions: map[string]*acm.CertificateDetail{
+			"test": {
+				CertificateArn: aws2.String("test"),
+				InUseBy:        []*string{aws2.String("arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/lb/1")},
+				NotAfter:       aws2.Time(time.Now().Add(30 * 24 * time.Hour)),
+			},
+		},
+	}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+	controller.Cache["foo/windowed"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{
+				Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+				Value: aws2.String("1"),
+			},
+		},
+		NotAfter: aws2.Time(time.Now().Add(10 * 24 * time.Hour)),
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "windowed",
+	}}
+	// Failing to describe the certificate is retried rather than reported as an invalid window
+	mockService.describeErr = errors.New("connection reset")
+	if _, err := controller.Reconcile(request); err == nil {
+		t.Error("Expected the describe error to be returned for a retry")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning DescribeFailed") {
+		t.Errorf("Unexpected event %q", event)
+	}
+	mockService.describeErr = nil
+
+	result, err := controller.Reconcile(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockService.input != nil {
+		t.Error("Expected the reimport to be deferred outside of the maintenance window")
+	}
+	if result.RequeueAfter <= time.Hour || result.RequeueAfter > 2*time.Hour {
+		t.Errorf("Expected a requeue at the start of the next window, got %v", result.RequeueAfter)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Deferred") {
+		t.Errorf("Unexpected event %q", event)
+	}
+	// Deferred certificates are still warned about as they approach their expiry
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning Expiring") {
+		t.Errorf("Unexpected event %q", event)
+	}
+
+	mockService.descriptions["test"].NotAfter = aws2.Time(time.Now().Add(24 * time.Hour))
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+	if mockService.input == nil {
+		t.Error("Expected a reimport when the ACM certificate is about to expire")
+	}
+}
+
+func TestExpiryGuard(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:       "expiring",
+			Namespace:  "foo",
+			Finalizers: []string{"certificate.legalzoom.com"},
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+		},
+	}
+
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(90*24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+	notAfter := time.Now().Add(10 * 24 * time.Hour)
+	controller.Cache["foo/expiring"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("test"),
+		},
+		Tags: []*acm.Tag{
+			{
+				Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+				Value: aws2.String("1"),
+			},
+		},
+		NotAfter: &notAfter,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "expiring",
+	}}
+	result, err := controller.Reconcile(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter <= 2*24*time.Hour || result.RequeueAfter > 3*24*time.Hour {
+		t.Errorf("Expected a requeue when the 7 day threshold is crossed, got %v", result.RequeueAfter)
+	}
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	if len(events) != 3 || !strings.HasPrefix(events[0], "Normal Skipped") ||
+		!strings.HasPrefix(events[1], "Warning Expiring") || !strings.Contains(events[1], "within 14d") ||
+		!strings.HasPrefix(events[2], "Warning RenewalNotImported") {
+		t.Errorf("Unexpected events %q", events)
+	}
+	if value, _ := gaugeValue(t, "acm_importer_expiring_certificates", map[string]string{"threshold": "14d"}); value != 1 {
+		t.Errorf("Expected one certificate expiring within 14 days, got %v", value)
+	}
+	if value, _ := gaugeValue(t, "acm_importer_unimported_renewals", nil); value != 1 {
+		t.Errorf("Expected one unimported renewal, got %v", value)
+	}
+
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Events) != 0 {
+		t.Errorf("Expected the skip and expiry warnings once, got %q", <-recorder.Events)
+	}
+}
+
+func TestParseExpiryThresholds(t *testing.T) {
+	thresholds, err := controllers.ParseExpiryThresholds("30d, 36h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 2 || thresholds[0] != 30*24*time.Hour || thresholds[1] != 36*time.Hour {
+		t.Errorf("Unexpected thresholds %v", thresholds)
+	}
+	if _, err := controllers.ParseExpiryThresholds("soon"); err == nil {
+		t.Error("Expected an invalid threshold to be refused")
+	}
+}
+
+func TestImportNotYetValid(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	notBefore := time.Now().Add(time.Hour)
+	basicSecret := newTLSSecret(t, "foo", "secret", notBefore, notBefore.Add(24*time.Hour), "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	recorder := record.NewFakeRecorder(10)
+	mockService := &MockService{}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+		Recorder:   recorder,
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "bar",
+	}}
+	result, err := controller.Reconcile(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mockService.input != nil {
+		t.Error("Certificate that is not yet valid was imported")
+	}
+	if result.RequeueAfter <= 59*time.Minute || result.RequeueAfter > time.Hour {
+		t.Errorf("Expected a requeue once the certificate is valid, got %v", result.RequeueAfter)
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal NotYetValid") {
+		t.Errorf("Unexpected event %q", event)
+	}
+}
+
+func TestImportErrorRequeue(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		returned  bool
+		min       time.Duration
+		max       time.Duration
+		lastError string
+	}{
+		{"retryable", errors.New("connection reset"), true, 0, 0, "connection reset"},
+		{"throttled", awserr.New("ThrottlingException", "Rate exceeded", nil), false, time.Minute, 90 * time.Second, "Throttled: ThrottlingException"},
+		{"quota", awserr.New(acm.ErrCodeLimitExceededException, "Import limit reached", nil), false, time.Hour, time.Hour, "Quota: LimitExceededException"},
+		{"permanent", awserr.NewRequestFailure(awserr.New("ValidationException", "Invalid certificate chain", nil), 400, "request-1"), false, 12 * time.Hour, 12 * time.Hour, "Permanent: ValidationException"},
+	}
+
+	for _, c := range cases {
+		basicCert := cmapiv1.Certificate{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					"legalzoom.com/import-to-acm": "true",
+				},
+				Name:      "bar",
+				Namespace: "foo",
+			},
+			Spec: cmapiv1.CertificateSpec{
+				SecretName: "secret",
+			},
+			Status: cmapiv1.CertificateStatus{
+				Revision: aws2.Int(2),
+			},
+		}
+
+		basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), time.Now().Add(24*time.Hour), "example.com")
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		cmapiv1.AddToScheme(scheme)
+		client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+		recorder := record.NewFakeRecorder(10)
+		mockService := &MockService{upsertErr: c.err}
+		controller := controllers.CertificateReconciler{
+			Client:     client,
+			Cache:      make(map[string]*controllers.AcmCertificate),
+			AcmService: mockService,
+			APIReader:  client,
+			Recorder:   recorder,
+		}
+		controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+			Summary: &acm.CertificateSummary{
+				CertificateArn: aws2.String("test"),
+			},
+			Tags: []*acm.Tag{
+				{
+					Key:   aws2.String("legalzoom.com/cert-importer/cert-revision"),
+					Value: aws2.String("1"),
+				},
+			},
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "bar",
+		}}
+		result, err := controller.Reconcile(request)
+		if (err != nil) != c.returned {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if result.RequeueAfter < c.min || result.RequeueAfter > c.max {
+			t.Errorf("%s: unexpected requeue after %v", c.name, result.RequeueAfter)
+		}
+		if event := <-recorder.Events; !strings.HasPrefix(event, "Warning ImportFailed") {
+			t.Errorf("%s: unexpected event %q", c.name, event)
+		}
+
+		var certificate cmapiv1.Certificate
+		if err := client.Get(context.Background(), request.NamespacedName, &certificate); err != nil {
+			t.Fatal(err)
+		}
+		if lastError := certificate.Annotations["legalzoom.com/acm-last-error"]; lastError != c.lastError {
+			t.Errorf("%s: unexpected last error %q", c.name, lastError)
+		}
+
+		// A repeated failure differing only in its request id leaves the Certificate alone.
+		if failure, ok := c.err.(awserr.RequestFailure); ok {
+			mockService.upsertErr = awserr.NewRequestFailure(awserr.New(failure.Code(), failure.Message(), nil), failure.StatusCode(), "request-2")
+			controller.Reconcile(request)
+			var repeated cmapiv1.Certificate
+			if err := client.Get(context.Background(), request.NamespacedName, &repeated); err != nil {
+				t.Fatal(err)
+			}
+			if repeated.ResourceVersion != certificate.ResourceVersion {
+				t.Errorf("%s: Certificate updated by a repeated failure", c.name)
+			}
+		}
+	}
+}
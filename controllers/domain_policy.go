@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"crypto/x509"
+	"fmt"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"io/ioutil"
+	"path"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// DomainPolicy restricts which DNS names and issuers each namespace may import into ACM
+type DomainPolicy struct {
+	Rules []DomainRule `json:"rules"`
+}
+
+// DomainRule allows the namespaces matching any of Namespaces to import certificates for DNS names
+// matching any of DNSNames, issued by any of Issuers
+type DomainRule struct {
+	// Namespaces are glob patterns matched against the namespace
+	Namespaces []string `json:"namespaces"`
+	// DNSNames are DNS name patterns, where a "*" label matches exactly one label
+	DNSNames []string `json:"dnsNames"`
+	// Issuers are issuer names prefixed with the kind, as in "ClusterIssuer/letsencrypt" or
+	// "Issuer/internal-ca". A name without a kind refers to a ClusterIssuer, since namespaced Issuers
+	// of that name can be created by any namespace. Any issuer is allowed when empty.
+	Issuers []string `json:"issuers,omitempty"`
+}
+
+// LoadDomainPolicy reads a DomainPolicy from a YAML file
+func LoadDomainPolicy(file string) (*DomainPolicy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var policy DomainPolicy
+	if err := yaml.UnmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("invalid domain policy %s: %v", file, err)
+	}
+	return &policy, nil
+}
+
+// dnsNameMatches matches a DNS name against a pattern label by label
+func dnsNameMatches(pattern string, name string) bool {
+	patternLabels := strings.Split(strings.ToLower(pattern), ".")
+	nameLabels := strings.Split(strings.ToLower(name), ".")
+	if len(patternLabels) != len(nameLabels) {
+		return false
+	}
+	for i := range patternLabels {
+		if patternLabels[i] != "*" && patternLabels[i] != nameLabels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateDNSNames returns the DNS names a certificate is valid for
+func certificateDNSNames(leaf *x509.Certificate) []string {
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames
+	}
+	if leaf.Subject.CommonName != "" {
+		return []string{leaf.Subject.CommonName}
+	}
+	return nil
+}
+
+// issuerReference returns the kind/name form of an issuer listed in a DomainRule
+func issuerReference(issuer string) string {
+	if !strings.Contains(issuer, "/") {
+		return cmapiv1.ClusterIssuerKind + "/" + issuer
+	}
+	return issuer
+}
+
+// Allows returns an error describing why the namespace may not import the certificate. issuerKind
+// and issuerName are empty when the issuer of the certificate is unknown.
+func (p *DomainPolicy) Allows(namespace string, issuerKind string, issuerName string, leaf *x509.Certificate) error {
+	var rules []DomainRule
+	for _, rule := range p.Rules {
+		for _, pattern := range rule.Namespaces {
+			if matched, _ := path.Match(pattern, namespace); matched {
+				rules = append(rules, rule)
+				break
+			}
+		}
+	}
+	if len(rules) == 0 {
+		return fmt.Errorf("namespace %s may not import certificates", namespace)
+	}
+
+	issuerAllowed := false
+	for _, rule := range rules {
+		if len(rule.Issuers) == 0 {
+			issuerAllowed = true
+		}
+		for _, issuer := range rule.Issuers {
+			if issuerName != "" && issuerReference(issuer) == issuerKind+"/"+issuerName {
+				issuerAllowed = true
+			}
+		}
+	}
+	if !issuerAllowed {
+		return fmt.Errorf("namespace %s may not import certificates issued by %s/%s", namespace, issuerKind, issuerName)
+	}
+
+	for _, name := range certificateDNSNames(leaf) {
+		allowed := false
+		for _, rule := range rules {
+			for _, pattern := range rule.DNSNames {
+				if dnsNameMatches(pattern, name) {
+					allowed = true
+				}
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("namespace %s may not import certificates for %s", namespace, name)
+		}
+	}
+	return nil
+}
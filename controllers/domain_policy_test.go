@@ -0,0 +1,55 @@
+package controllers_test
+
+import (
+	"crypto/x509"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	"testing"
+)
+
+func TestDomainPolicy(t *testing.T) {
+	policy := &controllers.DomainPolicy{
+		Rules: []controllers.DomainRule{
+			{
+				Namespaces: []string{"team-a", "team-a-*"},
+				DNSNames:   []string{"team-a.example.com", "*.team-a.example.com"},
+				Issuers:    []string{"ClusterIssuer/letsencrypt"},
+			},
+			{
+				Namespaces: []string{"team-b"},
+				DNSNames:   []string{"team-b.example.com"},
+				Issuers:    []string{"letsencrypt", "Issuer/internal-ca"},
+			},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		namespace  string
+		issuerKind string
+		issuerName string
+		dnsNames   []string
+		allowed    bool
+	}{
+		{"allowed", "team-a", "ClusterIssuer", "letsencrypt", []string{"team-a.example.com", "www.team-a.example.com"}, true},
+		{"namespace glob", "team-a-staging", "ClusterIssuer", "letsencrypt", []string{"api.team-a.example.com"}, true},
+		{"wildcard certificate", "team-a", "ClusterIssuer", "letsencrypt", []string{"*.team-a.example.com"}, true},
+		{"other namespace", "team-b", "ClusterIssuer", "letsencrypt", []string{"team-a.example.com"}, false},
+		{"other domain", "team-a", "ClusterIssuer", "letsencrypt", []string{"team-a.example.com", "example.com"}, false},
+		{"nested subdomain", "team-a", "ClusterIssuer", "letsencrypt", []string{"a.b.team-a.example.com"}, false},
+		{"namespaced issuer", "team-a", "Issuer", "letsencrypt", []string{"team-a.example.com"}, false},
+		{"unknown issuer", "team-a", "", "", []string{"team-a.example.com"}, false},
+		{"bare cluster issuer", "team-b", "ClusterIssuer", "letsencrypt", []string{"team-b.example.com"}, true},
+		{"bare name for namespaced issuer", "team-b", "Issuer", "letsencrypt", []string{"team-b.example.com"}, false},
+		{"namespaced issuer by kind", "team-b", "Issuer", "internal-ca", []string{"team-b.example.com"}, true},
+	}
+
+	for _, c := range cases {
+		err := policy.Allows(c.namespace, c.issuerKind, c.issuerName, &x509.Certificate{DNSNames: c.dnsNames})
+		if c.allowed && err != nil {
+			t.Errorf("%s: expected import to be allowed: %v", c.name, err)
+		}
+		if !c.allowed && err == nil {
+			t.Errorf("%s: expected import to be refused", c.name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"strconv"
+	"strings"
+)
+
+// preferredCertificate chooses deterministically between two ACM certificates carrying the same
+// cert-id: the one named by the Certificate's certificate-arn annotation, then the one with the
+// highest revision tag, then the one imported most recently, then the lowest ARN
+func (r *CertificateReconciler) preferredCertificate(certId string, a *AcmCertificate, b *AcmCertificate) (winner *AcmCertificate, loser *AcmCertificate) {
+	if annotatedArn := r.annotatedArn(certId); annotatedArn != "" {
+		if aws.StringValue(b.Summary.CertificateArn) == annotatedArn {
+			return b, a
+		}
+		if aws.StringValue(a.Summary.CertificateArn) == annotatedArn {
+			return a, b
+		}
+	}
+
+	revisionA, revisionB := revisionTag(a.Tags), revisionTag(b.Tags)
+	if revisionA != revisionB {
+		if revisionB > revisionA {
+			return b, a
+		}
+		return a, b
+	}
+
+	if a.ImportedAt != nil && b.ImportedAt != nil && !a.ImportedAt.Equal(*b.ImportedAt) {
+		if b.ImportedAt.After(*a.ImportedAt) {
+			return b, a
+		}
+		return a, b
+	}
+	if a.ImportedAt == nil && b.ImportedAt != nil {
+		return b, a
+	}
+	if b.ImportedAt == nil && a.ImportedAt != nil {
+		return a, b
+	}
+
+	if aws.StringValue(b.Summary.CertificateArn) < aws.StringValue(a.Summary.CertificateArn) {
+		return b, a
+	}
+	return a, b
+}
+
+// annotatedArn returns the certificate-arn annotation of the Certificate with the given cert-id,
+// read directly from the API server since the manager's cache is not started during warm-up
+func (r *CertificateReconciler) annotatedArn(certId string) string {
+	if r.APIReader == nil {
+		return ""
+	}
+	parts := strings.SplitN(certId, "/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	var certificate cmapiv1.Certificate
+	if err := r.APIReader.Get(context.Background(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, &certificate); err != nil {
+		return ""
+	}
+	return certificate.Annotations[certificateArnAnnotation]
+}
+
+// revisionTag returns the revision recorded in the tags of an ACM certificate, -1 when missing
+func revisionTag(tags []*acm.Tag) int {
+	value, ok := tagValue(tags, certRevisionAnnotation)
+	if !ok {
+		return -1
+	}
+	revision, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return revision
+}
+
+// resolveDuplicate reports an ACM certificate that carries the same cert-id as a cached one and
+// returns the one to keep. The other is deleted when DeleteDuplicates is set and it is not in use.
+func (r *CertificateReconciler) resolveDuplicate(certId string, existing *AcmCertificate, duplicate *AcmCertificate) *AcmCertificate {
+	winner, loser := r.preferredCertificate(certId, existing, duplicate)
+	zap.S().Warnw("Found duplicate ACM certificates for certificate",
+		"certificate", certId,
+		"kept", aws.StringValue(winner.Summary.CertificateArn),
+		"duplicate", aws.StringValue(loser.Summary.CertificateArn),
+	)
+	duplicateCertificates.Inc()
+
+	if !r.DeleteDuplicates {
+		return winner
+	}
+	acmService, err := r.acmServiceFor(loser.Region)
+	if err != nil {
+		zap.S().Errorw("Failed to delete duplicate certificate", "arn", aws.StringValue(loser.Summary.CertificateArn), "error", err)
+		return winner
+	}
+	description, err := acmService.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: loser.Summary.CertificateArn})
+	if err != nil {
+		zap.S().Errorw("Failed to delete duplicate certificate", "arn", aws.StringValue(loser.Summary.CertificateArn), "error", err)
+		return winner
+	}
+	if description.Certificate != nil && len(description.Certificate.InUseBy) > 0 {
+		zap.S().Warnw("Not deleting duplicate certificate that is in use",
+			"arn", aws.StringValue(loser.Summary.CertificateArn),
+			"inUseBy", aws.StringValueSlice(description.Certificate.InUseBy),
+		)
+		return winner
+	}
+	if _, err := acmService.DeleteCertificate(&acm.DeleteCertificateInput{CertificateArn: loser.Summary.CertificateArn}); err != nil {
+		if _, ok := err.(*acm.ResourceNotFoundException); !ok {
+			zap.S().Errorw("Failed to delete duplicate certificate", "arn", aws.StringValue(loser.Summary.CertificateArn), "error", err)
+		}
+		return winner
+	}
+	if r.DryRun {
+		return winner
+	}
+	zap.S().Infow("Deleted duplicate certificate", "certificate", certId, "arn", aws.StringValue(loser.Summary.CertificateArn))
+	deletesTotal.WithLabelValues("Certificate").Inc()
+	return winner
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"crypto/x509"
+	"fmt"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultExpiryThresholds are how long before the ACM copy of a Certificate expires warnings are raised
+var DefaultExpiryThresholds = []time.Duration{30 * 24 * time.Hour, 14 * 24 * time.Hour, 7 * 24 * time.Hour}
+
+// ParseExpiryThresholds parses a comma separated list of durations, which may also be given in days as in "30d".
+// An empty list disables the warnings.
+func ParseExpiryThresholds(value string) ([]time.Duration, error) {
+	thresholds := []time.Duration{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var threshold time.Duration
+		if strings.HasSuffix(field, "d") {
+			days, err := strconv.Atoi(strings.TrimSuffix(field, "d"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid expiry threshold %q", field)
+			}
+			threshold = time.Duration(days) * 24 * time.Hour
+		} else {
+			var err error
+			if threshold, err = time.ParseDuration(field); err != nil {
+				return nil, fmt.Errorf("invalid expiry threshold %q", field)
+			}
+		}
+		if threshold <= 0 {
+			return nil, fmt.Errorf("expiry threshold %q must be positive", field)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+// formatThreshold formats whole days as in "30d", other thresholds as durations
+func formatThreshold(threshold time.Duration) string {
+	if threshold%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", threshold/(24*time.Hour))
+	}
+	return threshold.String()
+}
+
+func (r *CertificateReconciler) expiryThresholds() []time.Duration {
+	return sortedExpiryThresholds(r.ExpiryThresholds)
+}
+
+// sortedExpiryThresholds returns the thresholds in ascending order, DefaultExpiryThresholds when nil
+func sortedExpiryThresholds(thresholds []time.Duration) []time.Duration {
+	if thresholds == nil {
+		thresholds = DefaultExpiryThresholds
+	}
+	sorted := append([]time.Duration{}, thresholds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// notYetValidFor returns how long until the certificate becomes valid, zero when its NotBefore has passed
+func notYetValidFor(leaf *x509.Certificate, now time.Time) time.Duration {
+	if leaf.NotBefore.After(now) {
+		return leaf.NotBefore.Sub(now)
+	}
+	return 0
+}
+
+// crossedThreshold returns the smallest threshold a certificate expiring at notAfter is within, zero when none
+func crossedThreshold(thresholds []time.Duration, notAfter time.Time, now time.Time) time.Duration {
+	for _, threshold := range thresholds {
+		if notAfter.Sub(now) <= threshold {
+			return threshold
+		}
+	}
+	return 0
+}
+
+// nextExpiryCheck returns how long until a certificate expiring at notAfter crosses its next threshold, or
+// expires once all have been crossed, zero when it has already expired
+func nextExpiryCheck(thresholds []time.Duration, notAfter time.Time, now time.Time) time.Duration {
+	for i := len(thresholds) - 1; i >= 0; i-- {
+		if next := notAfter.Add(-thresholds[i]).Sub(now); next > 0 {
+			return next
+		}
+	}
+	if next := notAfter.Sub(now); next > 0 {
+		return next
+	}
+	return 0
+}
+
+// requeueSooner returns result, requeued after the given duration instead when that is sooner
+func requeueSooner(result ctrl.Result, after time.Duration) ctrl.Result {
+	if result.RequeueAfter == 0 || after < result.RequeueAfter {
+		result.RequeueAfter = after
+	}
+	return result
+}
+
+// checkExpiry warns when the ACM copy of the Certificate crosses an expiry threshold, and whether its Secret
+// holds a renewal that was never imported. The returned Result requeues the Certificate when the next
+// threshold is crossed, so that renewals failing silently are noticed before the certificate expires.
+func (r *CertificateReconciler) checkExpiry(certificate *cmapiv1.Certificate, certId string, now time.Time) ctrl.Result {
+	mutex.RLock()
+	entry := r.Cache[certId]
+	var notAfter *time.Time
+	if entry != nil {
+		notAfter = entry.NotAfter
+	}
+	mutex.RUnlock()
+	if notAfter == nil {
+		forgetExpiry(certId)
+		return ctrl.Result{}
+	}
+
+	secretLeaf := func() (*x509.Certificate, error) {
+		return r.GetCertificateSecret(*certificate).Leaf()
+	}
+	return warnExpiry(r.Recorder, certificate, certId, *notAfter, secretLeaf, certificate.Spec.SecretName, r.expiryThresholds(), now)
+}
+
+// warnExpiry warns when the ACM certificate of the object with the given key, expiring at notAfter, crosses
+// one of the sorted thresholds, and whether its Secret holds a renewal that was never imported. secretLeaf
+// is only parsed once a threshold is crossed. The returned Result requeues the object when the next
+// threshold is crossed.
+func warnExpiry(recorder record.EventRecorder, object runtime.Object, key string, notAfter time.Time, secretLeaf func() (*x509.Certificate, error), secretName string, thresholds []time.Duration, now time.Time) ctrl.Result {
+	threshold := crossedThreshold(thresholds, notAfter, now)
+	previous := setExpiring(key, threshold)
+	if threshold == 0 {
+		setUnimportedRenewal(key, false)
+		return ctrl.Result{RequeueAfter: nextExpiryCheck(thresholds, notAfter, now)}
+	}
+
+	renewed := false
+	if leaf, err := secretLeaf(); err == nil && leaf.NotAfter.After(notAfter) {
+		renewed = true
+	}
+	newlyRenewed := setUnimportedRenewal(key, renewed)
+	if threshold != previous {
+		zap.S().Warnw("Certificate in ACM is expiring",
+			"certificate", key,
+			"notAfter", notAfter.UTC().Format(time.RFC3339),
+			"threshold", formatThreshold(threshold),
+		)
+		recordEvent(recorder, object, v1.EventTypeWarning, "Expiring",
+			"Certificate in ACM expires at %s, within %s", notAfter.UTC().Format(time.RFC3339), formatThreshold(threshold))
+	}
+	if renewed && (newlyRenewed || threshold != previous) {
+		zap.S().Warnw("Secret holds a renewed certificate that was not imported",
+			"certificate", key,
+			"secret", secretName,
+		)
+		recordEvent(recorder, object, v1.EventTypeWarning, "RenewalNotImported",
+			"Secret %s holds a certificate expiring after the one in ACM, but it was not imported", secretName)
+	}
+	return ctrl.Result{RequeueAfter: nextExpiryCheck(thresholds, notAfter, now)}
+}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
+)
+
+// GatewayReconciler writes the ACM ARNs of managed Certificates into an annotation on the
+// Gateway API Gateways whose listeners reference the Certificate's Secret
+type GatewayReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	Cache  map[string]*AcmCertificate
+	// ArnAnnotation is the Gateway annotation that receives a comma separated list of ARNs
+	ArnAnnotation string
+}
+
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
+
+var (
+	GatewayGVK = schema.GroupVersionKind{
+		Group:   "gateway.networking.k8s.io",
+		Version: "v1",
+		Kind:    "Gateway",
+	}
+	ReferenceGrantGVK = schema.GroupVersionKind{
+		Group:   "gateway.networking.k8s.io",
+		Version: "v1beta1",
+		Kind:    "ReferenceGrant",
+	}
+	DefaultGatewayArnAnnotation = "legalzoom.com/certificate-arns"
+)
+
+func newGateway() *unstructured.Unstructured {
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(GatewayGVK)
+	return gateway
+}
+
+func newReferenceGrant() *unstructured.Unstructured {
+	grant := &unstructured.Unstructured{}
+	grant.SetGroupVersionKind(ReferenceGrantGVK)
+	return grant
+}
+
+// gatewaySecretRefs returns the Secrets referenced by the TLS configuration of the Gateway's listeners
+func gatewaySecretRefs(gateway *unstructured.Unstructured) []types.NamespacedName {
+	var refs []types.NamespacedName
+	listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	for _, listener := range listeners {
+		listenerMap, ok := listener.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		certificateRefs, _, _ := unstructured.NestedSlice(listenerMap, "tls", "certificateRefs")
+		for _, certificateRef := range certificateRefs {
+			refMap, ok := certificateRef.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _, _ := unstructured.NestedString(refMap, "group")
+			kind, _, _ := unstructured.NestedString(refMap, "kind")
+			if group != "" || (kind != "" && kind != "Secret") {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(refMap, "name")
+			namespace, _, _ := unstructured.NestedString(refMap, "namespace")
+			if namespace == "" {
+				namespace = gateway.GetNamespace()
+			}
+			refs = append(refs, types.NamespacedName{Namespace: namespace, Name: name})
+		}
+	}
+	return refs
+}
+
+// grantAllows reports whether a ReferenceGrant allows Gateways in gatewayNamespace to reference the
+// Secret with the given name in the grant's namespace
+func grantAllows(grant *unstructured.Unstructured, gatewayNamespace string, secretName string) bool {
+	fromAllowed := false
+	from, _, _ := unstructured.NestedSlice(grant.Object, "spec", "from")
+	for _, entry := range from {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(entryMap, "group")
+		kind, _, _ := unstructured.NestedString(entryMap, "kind")
+		namespace, _, _ := unstructured.NestedString(entryMap, "namespace")
+		if group == GatewayGVK.Group && kind == GatewayGVK.Kind && namespace == gatewayNamespace {
+			fromAllowed = true
+			break
+		}
+	}
+	if !fromAllowed {
+		return false
+	}
+	to, _, _ := unstructured.NestedSlice(grant.Object, "spec", "to")
+	for _, entry := range to {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(entryMap, "group")
+		kind, _, _ := unstructured.NestedString(entryMap, "kind")
+		name, _, _ := unstructured.NestedString(entryMap, "name")
+		if group == "" && kind == "Secret" && (name == "" || name == secretName) {
+			return true
+		}
+	}
+	return false
+}
+
+// referenceGranted reports whether a ReferenceGrant in the Secret's namespace allows Gateways in
+// gatewayNamespace to reference it. Gateways may always reference Secrets in their own namespace.
+func (r *GatewayReconciler) referenceGranted(ctx context.Context, gatewayNamespace string, secretRef types.NamespacedName) (bool, error) {
+	if secretRef.Namespace == gatewayNamespace {
+		return true, nil
+	}
+	grants := &unstructured.UnstructuredList{}
+	grants.SetGroupVersionKind(ReferenceGrantGVK.GroupVersion().WithKind(ReferenceGrantGVK.Kind + "List"))
+	if err := r.List(ctx, grants, client.InNamespace(secretRef.Namespace)); err != nil {
+		return false, err
+	}
+	for i := range grants.Items {
+		if grantAllows(&grants.Items[i], gatewayNamespace, secretRef.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *GatewayReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	gateway := newGateway()
+	if err := r.Get(ctx, req.NamespacedName, gateway); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var arns []string
+	for _, secretRef := range gatewaySecretRefs(gateway) {
+		granted, err := r.referenceGranted(ctx, gateway.GetNamespace(), secretRef)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !granted {
+			zap.S().Infow("Ignoring certificate reference without a ReferenceGrant",
+				"gateway", req.NamespacedName.String(),
+				"secret", secretRef.String(),
+			)
+			continue
+		}
+		var certificates cmapiv1.CertificateList
+		if err := r.List(ctx, &certificates, client.InNamespace(secretRef.Namespace)); err != nil {
+			return ctrl.Result{}, err
+		}
+		for _, certificate := range certificates.Items {
+			if certificate.Spec.SecretName != secretRef.Name {
+				continue
+			}
+			mutex.RLock()
+			cachedEntry := r.Cache[types.NamespacedName{Namespace: certificate.Namespace, Name: certificate.Name}.String()]
+			mutex.RUnlock()
+			if cachedEntry == nil || cachedEntry.Summary == nil || cachedEntry.Summary.CertificateArn == nil {
+				continue
+			}
+			if !contains(arns, *cachedEntry.Summary.CertificateArn) {
+				arns = append(arns, *cachedEntry.Summary.CertificateArn)
+			}
+		}
+	}
+
+	annotations := gateway.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	current, annotated := annotations[r.ArnAnnotation]
+	value := strings.Join(arns, ",")
+	if len(arns) == 0 {
+		if !annotated {
+			return ctrl.Result{}, nil
+		}
+		zap.S().Infow("Removing certificate arn annotation from gateway",
+			"gateway", req.NamespacedName.String(),
+		)
+		delete(annotations, r.ArnAnnotation)
+	} else {
+		if current == value {
+			return ctrl.Result{}, nil
+		}
+		zap.S().Infow("Setting certificate arn annotation for gateway",
+			"gateway", req.NamespacedName.String(),
+			"arns", value,
+		)
+		annotations[r.ArnAnnotation] = value
+	}
+	gateway.SetAnnotations(annotations)
+	if err := r.Update(ctx, gateway); err != nil {
+		zap.S().Errorw("Error occurred updating gateway",
+			"gateway", req.NamespacedName.String(),
+			"error", err,
+		)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// gatewaysForCertificate maps a Certificate to the Gateways whose listeners reference its Secret
+func (r *GatewayReconciler) gatewaysForCertificate(obj handler.MapObject) []reconcile.Request {
+	certificate, ok := obj.Object.(*cmapiv1.Certificate)
+	if !ok {
+		return nil
+	}
+
+	gateways := &unstructured.UnstructuredList{}
+	gateways.SetGroupVersionKind(GatewayGVK.GroupVersion().WithKind(GatewayGVK.Kind + "List"))
+	if err := r.List(context.Background(), gateways); err != nil {
+		zap.S().Errorw("Failed to list gateways", "error", err)
+		return nil
+	}
+
+	secret := types.NamespacedName{Namespace: certificate.Namespace, Name: certificate.Spec.SecretName}
+	var requests []reconcile.Request
+	for i := range gateways.Items {
+		for _, secretRef := range gatewaySecretRefs(&gateways.Items[i]) {
+			if secretRef == secret {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Namespace: gateways.Items[i].GetNamespace(),
+					Name:      gateways.Items[i].GetName(),
+				}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// gatewaysForReferenceGrant maps a ReferenceGrant to the Gateways in the namespaces it grants access to
+func (r *GatewayReconciler) gatewaysForReferenceGrant(obj handler.MapObject) []reconcile.Request {
+	grant, ok := obj.Object.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	from, _, _ := unstructured.NestedSlice(grant.Object, "spec", "from")
+	for _, entry := range from {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		namespace, _, _ := unstructured.NestedString(entryMap, "namespace")
+		if namespace == "" {
+			continue
+		}
+		gateways := &unstructured.UnstructuredList{}
+		gateways.SetGroupVersionKind(GatewayGVK.GroupVersion().WithKind(GatewayGVK.Kind + "List"))
+		if err := r.List(context.Background(), gateways, client.InNamespace(namespace)); err != nil {
+			zap.S().Errorw("Failed to list gateways", "namespace", namespace, "error", err)
+			continue
+		}
+		for i := range gateways.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: gateways.Items[i].GetNamespace(),
+				Name:      gateways.Items[i].GetName(),
+			}})
+		}
+	}
+	return requests
+}
+
+func (r *GatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.ArnAnnotation == "" {
+		r.ArnAnnotation = DefaultGatewayArnAnnotation
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(newGateway()).
+		Watches(&source.Kind{Type: &cmapiv1.Certificate{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.gatewaysForCertificate),
+		}).
+		Watches(&source.Kind{Type: newReferenceGrant()}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.gatewaysForReferenceGrant),
+		}).
+		Complete(r)
+}
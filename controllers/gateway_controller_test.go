@@ -0,0 +1,172 @@
+package controllers_test
+
+import (
+	"context"
+	aws2 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestGatewayArnAnnotation(t *testing.T) {
+	basicCert := &cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+	}
+
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(controllers.GatewayGVK)
+	gateway.SetName("gateway")
+	gateway.SetNamespace("foo")
+	_ = unstructured.SetNestedSlice(gateway.Object, []interface{}{
+		map[string]interface{}{
+			"name": "https",
+			"tls": map[string]interface{}{
+				"certificateRefs": []interface{}{
+					map[string]interface{}{
+						"name": "secret",
+					},
+				},
+			},
+		},
+	}, "spec", "listeners")
+
+	scheme := runtime.NewScheme()
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, basicCert, gateway)
+	controller := controllers.GatewayReconciler{
+		Client:        client,
+		Cache:         make(map[string]*controllers.AcmCertificate),
+		ArnAnnotation: controllers.DefaultGatewayArnAnnotation,
+	}
+
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("arn"),
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "gateway",
+	}}
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := &unstructured.Unstructured{}
+	updated.SetGroupVersionKind(controllers.GatewayGVK)
+	if err := client.Get(context.Background(), request.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetAnnotations()[controllers.DefaultGatewayArnAnnotation] != "arn" {
+		t.Error("Incorrect certificate arn annotation")
+	}
+}
+
+func TestGatewayReferenceGrant(t *testing.T) {
+	basicCert := &cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "bar",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+	}
+
+	// The Gateway was annotated before, so the annotation is removed while it has no ReferenceGrant
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(controllers.GatewayGVK)
+	gateway.SetName("gateway")
+	gateway.SetNamespace("ingress")
+	gateway.SetAnnotations(map[string]string{controllers.DefaultGatewayArnAnnotation: "arn"})
+	_ = unstructured.SetNestedSlice(gateway.Object, []interface{}{
+		map[string]interface{}{
+			"name": "https",
+			"tls": map[string]interface{}{
+				"certificateRefs": []interface{}{
+					map[string]interface{}{
+						"name":      "secret",
+						"namespace": "foo",
+					},
+				},
+			},
+		},
+	}, "spec", "listeners")
+
+	grant := &unstructured.Unstructured{}
+	grant.SetGroupVersionKind(controllers.ReferenceGrantGVK)
+	grant.SetName("ingress")
+	grant.SetNamespace("foo")
+	_ = unstructured.SetNestedSlice(grant.Object, []interface{}{
+		map[string]interface{}{
+			"group":     "gateway.networking.k8s.io",
+			"kind":      "Gateway",
+			"namespace": "ingress",
+		},
+	}, "spec", "from")
+	_ = unstructured.SetNestedSlice(grant.Object, []interface{}{
+		map[string]interface{}{
+			"group": "",
+			"kind":  "Secret",
+			"name":  "secret",
+		},
+	}, "spec", "to")
+
+	cases := []struct {
+		name    string
+		objects []runtime.Object
+		arns    string
+	}{
+		{"without grant", []runtime.Object{basicCert, gateway.DeepCopy()}, ""},
+		{"with grant", []runtime.Object{basicCert, gateway.DeepCopy(), grant}, "arn"},
+	}
+
+	for _, c := range cases {
+		scheme := runtime.NewScheme()
+		cmapiv1.AddToScheme(scheme)
+		scheme.AddKnownTypeWithName(controllers.ReferenceGrantGVK.GroupVersion().WithKind("ReferenceGrantList"), &unstructured.UnstructuredList{})
+		client := fake.NewFakeClientWithScheme(scheme, c.objects...)
+		controller := controllers.GatewayReconciler{
+			Client:        client,
+			Cache:         make(map[string]*controllers.AcmCertificate),
+			ArnAnnotation: controllers.DefaultGatewayArnAnnotation,
+		}
+
+		controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+			Summary: &acm.CertificateSummary{
+				CertificateArn: aws2.String("arn"),
+			},
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "ingress",
+			Name:      "gateway",
+		}}
+		if _, err := controller.Reconcile(request); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		updated := &unstructured.Unstructured{}
+		updated.SetGroupVersionKind(controllers.GatewayGVK)
+		if err := client.Get(context.Background(), request.NamespacedName, updated); err != nil {
+			t.Fatal(err)
+		}
+		if arns := updated.GetAnnotations()[controllers.DefaultGatewayArnAnnotation]; arns != c.arns {
+			t.Errorf("%s: unexpected certificate arn annotation %q", c.name, arns)
+		}
+	}
+}
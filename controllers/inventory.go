@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	"go.uber.org/zap"
+	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultExpiringWithin is how close to its NotAfter an ACM certificate is reported as expiring
+	DefaultExpiringWithin = 30 * 24 * time.Hour
+	// DefaultInventoryCacheDuration is how long the inventory served over HTTP is reused before ACM is
+	// listed again
+	DefaultInventoryCacheDuration = 5 * time.Minute
+)
+
+// InventoryEntry describes one ACM certificate and the Certificate that owns it
+type InventoryEntry struct {
+	Arn       string     `json:"arn"`
+	Region    string     `json:"region,omitempty"`
+	Domains   []string   `json:"domains"`
+	NotAfter  *time.Time `json:"notAfter,omitempty"`
+	InUseBy   []string   `json:"inUseBy"`
+	CertId    string     `json:"certId,omitempty"`
+	ClusterId string     `json:"clusterId,omitempty"`
+	Revision  *int       `json:"revision,omitempty"`
+	// Managed is set when the Certificate named by CertId exists and is managed
+	Managed bool `json:"managed"`
+	// Orphaned is set when the certificate was imported by this cluster but its Certificate no longer
+	// exists or is no longer managed
+	Orphaned bool `json:"orphaned"`
+	// Unused is set when no AWS resource uses the certificate
+	Unused bool `json:"unused"`
+	// Expiring is set when the certificate expires within the reporting window
+	Expiring bool `json:"expiring"`
+}
+
+// Inventory describes every ACM certificate in the default and additional regions, sorted by ARN and
+// joined with the Certificates in the cluster. Certificates expiring within expiringWithin are reported as expiring.
+func (r *CertificateReconciler) Inventory(expiringWithin time.Duration) ([]InventoryEntry, error) {
+	regions := append([]string{""}, r.Regions...)
+	var entries []InventoryEntry
+	for _, region := range regions {
+		acmService, err := r.acmServiceFor(region)
+		if err != nil {
+			return nil, err
+		}
+		regionEntries, err := r.regionInventory(acmService, region, expiringWithin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list certificates in region %q: %v", region, err)
+		}
+		entries = append(entries, regionEntries...)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Arn < entries[j].Arn })
+	return entries, nil
+}
+
+func (r *CertificateReconciler) regionInventory(acmService aws2.IAcmService, region string, expiringWithin time.Duration) ([]InventoryEntry, error) {
+	var entries []InventoryEntry
+	var nextToken *string
+	for {
+		certs, err := acmService.ListCertificates(listCertificatesInput(nextToken))
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certs.CertificateSummaryList {
+			entry, err := r.inventoryEntry(acmService, cert, expiringWithin)
+			if err != nil {
+				return nil, err
+			}
+			entry.Region = region
+			entries = append(entries, entry)
+		}
+		if aws.StringValue(certs.NextToken) == "" {
+			return entries, nil
+		}
+		nextToken = certs.NextToken
+	}
+}
+
+func (r *CertificateReconciler) inventoryEntry(acmService aws2.IAcmService, cert *acm.CertificateSummary, expiringWithin time.Duration) (InventoryEntry, error) {
+	entry := InventoryEntry{
+		Arn:     aws.StringValue(cert.CertificateArn),
+		Domains: []string{aws.StringValue(cert.DomainName)},
+		InUseBy: []string{},
+	}
+
+	description, err := acmService.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: cert.CertificateArn})
+	if err != nil {
+		return entry, err
+	}
+	if detail := description.Certificate; detail != nil {
+		if len(detail.SubjectAlternativeNames) > 0 {
+			entry.Domains = aws.StringValueSlice(detail.SubjectAlternativeNames)
+		}
+		entry.NotAfter = detail.NotAfter
+		entry.InUseBy = append(entry.InUseBy, aws.StringValueSlice(detail.InUseBy)...)
+	}
+	entry.Unused = len(entry.InUseBy) == 0
+	entry.Expiring = entry.NotAfter != nil && time.Until(*entry.NotAfter) < expiringWithin
+
+	tags, err := acmService.ListTagsForCertificate(&acm.ListTagsForCertificateInput{CertificateArn: cert.CertificateArn})
+	if err != nil {
+		return entry, err
+	}
+	entry.CertId, _ = tagValue(tags.Tags, certIdAnnotation)
+	entry.ClusterId, _ = tagValue(tags.Tags, clusterIdTag)
+	if revision := revisionTag(tags.Tags); revision >= 0 {
+		entry.Revision = &revision
+	}
+	if entry.CertId == "" || !clusterMatches(r.ClusterId, r.ClaimUntagged, tags.Tags) {
+		return entry, nil
+	}
+
+	parts := strings.SplitN(entry.CertId, "/", 2)
+	if len(parts) != 2 {
+		return entry, nil
+	}
+	var certificate cmapiv1.Certificate
+	err = r.APIReader.Get(context.Background(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, &certificate)
+	if apierrors.IsNotFound(err) {
+		entry.Orphaned = true
+		return entry, nil
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Managed = contains(certificate.Finalizers, finalizer) || r.CertificateIsManaged(&certificate)
+	entry.Orphaned = !entry.Managed
+	return entry, nil
+}
+
+// WriteInventoryJSON writes the inventory as a JSON array
+func WriteInventoryJSON(w io.Writer, entries []InventoryEntry) error {
+	if entries == nil {
+		entries = []InventoryEntry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// WriteInventoryCSV writes the inventory as CSV with a header row. Lists are separated by spaces.
+func WriteInventoryCSV(w io.Writer, entries []InventoryEntry) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"arn", "region", "domains", "notAfter", "inUseBy", "certId", "clusterId", "revision", "managed", "orphaned", "unused", "expiring"})
+	for _, entry := range entries {
+		notAfter, revision := "", ""
+		if entry.NotAfter != nil {
+			notAfter = entry.NotAfter.UTC().Format(time.RFC3339)
+		}
+		if entry.Revision != nil {
+			revision = strconv.Itoa(*entry.Revision)
+		}
+		writer.Write([]string{
+			entry.Arn,
+			entry.Region,
+			strings.Join(entry.Domains, " "),
+			notAfter,
+			strings.Join(entry.InUseBy, " "),
+			entry.CertId,
+			entry.ClusterId,
+			revision,
+			strconv.FormatBool(entry.Managed),
+			strconv.FormatBool(entry.Orphaned),
+			strconv.FormatBool(entry.Unused),
+			strconv.FormatBool(entry.Expiring),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// InventoryHandler serves the inventory as JSON, or as CSV with ?format=csv. The reporting window is
+// taken from ?expiringWithin, a Go duration, defaulting to DefaultExpiringWithin. The inventory is
+// reused for cacheFor, so that frequent requests don't each describe every certificate in ACM.
+func (r *CertificateReconciler) InventoryHandler(cacheFor time.Duration) http.Handler {
+	var lock sync.Mutex
+	var cached []InventoryEntry
+	var cachedAt time.Time
+	inventory := func() ([]InventoryEntry, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if cached != nil && time.Since(cachedAt) < cacheFor {
+			return cached, nil
+		}
+		entries, err := r.Inventory(DefaultExpiringWithin)
+		if err != nil {
+			return nil, err
+		}
+		cached, cachedAt = entries, time.Now()
+		return entries, nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		expiringWithin := DefaultExpiringWithin
+		if value := req.URL.Query().Get("expiringWithin"); value != "" {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid expiringWithin: %v", err), http.StatusBadRequest)
+				return
+			}
+			expiringWithin = duration
+		}
+
+		cachedEntries, err := inventory()
+		if err != nil {
+			zap.S().Errorw("Failed to build inventory", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		entries := make([]InventoryEntry, len(cachedEntries))
+		for i, entry := range cachedEntries {
+			entry.Expiring = entry.NotAfter != nil && time.Until(*entry.NotAfter) < expiringWithin
+			entries[i] = entry
+		}
+		if req.URL.Query().Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			err = WriteInventoryCSV(w, entries)
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			err = WriteInventoryJSON(w, entries)
+		}
+		if err != nil {
+			zap.S().Errorw("Failed to write inventory", "error", err)
+		}
+	})
+}
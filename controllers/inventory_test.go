@@ -0,0 +1,129 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	aws2 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInventory(t *testing.T) {
+	managedCert := &cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Name:       "managed",
+			Namespace:  "foo",
+			Finalizers: []string{"certificate.legalzoom.com"},
+		},
+	}
+	scheme := runtime.NewScheme()
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, managedCert)
+
+	certIdTag := func(value string) []*acm.Tag {
+		return []*acm.Tag{{Key: aws2.String("legalzoom.com/cert-importer/cert-id"), Value: aws2.String(value)}}
+	}
+	soon := time.Now().Add(24 * time.Hour)
+	later := time.Now().Add(90 * 24 * time.Hour)
+	mockService := &MockService{
+		certificates: []*acm.CertificateSummary{
+			{CertificateArn: aws2.String("a-managed"), DomainName: aws2.String("example.com")},
+			{CertificateArn: aws2.String("b-orphaned"), DomainName: aws2.String("old.example.com")},
+			{CertificateArn: aws2.String("c-foreign"), DomainName: aws2.String("other.example.com")},
+		},
+		tags: map[string][]*acm.Tag{
+			"a-managed":  certIdTag("foo/managed"),
+			"b-orphaned": certIdTag("foo/deleted"),
+		},
+		keyTypes: map[string]string{
+			"a-managed": acm.KeyAlgorithmEcPrime256v1,
+		},
+		descriptions: map[string]*acm.CertificateDetail{
+			"a-managed": {
+				SubjectAlternativeNames: aws2.StringSlice([]string{"example.com", "www.example.com"}),
+				NotAfter:                &later,
+				InUseBy:                 aws2.StringSlice([]string{"arn:aws:elasticloadbalancing:listener"}),
+			},
+			"b-orphaned": {NotAfter: &soon},
+		},
+	}
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+		APIReader:  client,
+	}
+
+	entries, err := controller.Inventory(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	managed, orphaned, foreign := entries[0], entries[1], entries[2]
+	if !managed.Managed || managed.Orphaned || managed.Unused || managed.Expiring || len(managed.Domains) != 2 {
+		t.Errorf("Unexpected entry for managed certificate %+v", managed)
+	}
+	if orphaned.Managed || !orphaned.Orphaned || !orphaned.Unused || !orphaned.Expiring {
+		t.Errorf("Unexpected entry for orphaned certificate %+v", orphaned)
+	}
+	if foreign.Managed || foreign.Orphaned || foreign.CertId != "" || foreign.Domains[0] != "other.example.com" {
+		t.Errorf("Unexpected entry for foreign certificate %+v", foreign)
+	}
+
+	var output bytes.Buffer
+	if err := controllers.WriteInventoryCSV(&output, entries); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "a-managed,,example.com www.example.com,") {
+		t.Errorf("Unexpected CSV output %q", output.String())
+	}
+}
+
+func TestInventoryHandler(t *testing.T) {
+	later := time.Now().Add(90 * 24 * time.Hour)
+	mockService := &MockService{
+		certificates: []*acm.CertificateSummary{
+			{CertificateArn: aws2.String("a"), DomainName: aws2.String("example.com")},
+		},
+		descriptions: map[string]*acm.CertificateDetail{
+			"a": {NotAfter: &later},
+		},
+	}
+	controller := controllers.CertificateReconciler{
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: mockService,
+	}
+	handler := controller.InventoryHandler(time.Hour)
+
+	get := func(url string) []controllers.InventoryEntry {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", url, nil))
+		var entries []controllers.InventoryEntry
+		if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("Unexpected response %q: %v", recorder.Body.String(), err)
+		}
+		return entries
+	}
+
+	if entries := get("/inventory"); len(entries) != 1 || entries[0].Expiring {
+		t.Fatalf("Unexpected inventory %+v", entries)
+	}
+
+	// The inventory is reused, with the reporting window applied per request
+	mockService.certificates = append(mockService.certificates, &acm.CertificateSummary{CertificateArn: aws2.String("b")})
+	if entries := get("/inventory?expiringWithin=2400h"); len(entries) != 1 || !entries[0].Expiring {
+		t.Errorf("Unexpected cached inventory %+v", entries)
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	"github.com/robfig/cron/v3"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+// maintenanceWindowAnnotation restricts reimports into certificates that are in use to a window, given
+// on the Certificate or its Namespace as a cron expression for the start followed by a duration, as in
+// "0 2 * * SAT 4h"
+var maintenanceWindowAnnotation = "legalzoom.com/acm-maintenance-window"
+
+// DefaultMaintenanceWindowOverride is how close to its NotAfter an ACM certificate is reimported
+// regardless of its maintenance window
+var DefaultMaintenanceWindowOverride = 7 * 24 * time.Hour
+
+// maintenanceWindow is a recurring window starting on a cron schedule
+type maintenanceWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+func parseMaintenanceWindow(value string) (*maintenanceWindow, error) {
+	value = strings.TrimSpace(value)
+	separator := strings.LastIndex(value, " ")
+	if separator < 0 {
+		return nil, fmt.Errorf("maintenance window %q must be a cron expression followed by a duration", value)
+	}
+	duration, err := time.ParseDuration(value[separator+1:])
+	if err != nil || duration <= 0 {
+		return nil, fmt.Errorf("maintenance window %q has an invalid duration", value)
+	}
+	schedule, err := cron.ParseStandard(strings.TrimSpace(value[:separator]))
+	if err != nil {
+		return nil, fmt.Errorf("maintenance window %q has an invalid schedule: %v", value, err)
+	}
+	return &maintenanceWindow{schedule: schedule, duration: duration}, nil
+}
+
+// next returns the zero time when now is within the window, the start of the next window otherwise
+func (w *maintenanceWindow) next(now time.Time) time.Time {
+	if start := w.schedule.Next(now.Add(-w.duration)); !start.After(now) {
+		return time.Time{}
+	}
+	return w.schedule.Next(now)
+}
+
+// maintenanceWindowFor returns the maintenance window of the Certificate, taken from its own annotation
+// or else its Namespace's, nil when it has none
+func (r *CertificateReconciler) maintenanceWindowFor(certificate *cmapiv1.Certificate) (*maintenanceWindow, error) {
+	return objectMaintenanceWindow(r.Client, &certificate.ObjectMeta)
+}
+
+// objectMaintenanceWindow returns the maintenance window of an object, taken from its own annotation
+// or else its Namespace's, nil when it has none
+func objectMaintenanceWindow(reader client.Reader, object *metav1.ObjectMeta) (*maintenanceWindow, error) {
+	value := object.Annotations[maintenanceWindowAnnotation]
+	if value == "" {
+		var namespace v1.Namespace
+		if err := reader.Get(context.Background(), types.NamespacedName{Name: object.Namespace}, &namespace); err == nil {
+			value = namespace.Annotations[maintenanceWindowAnnotation]
+		}
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return parseMaintenanceWindow(value)
+}
+
+// deferReimport returns how long to defer reimporting into an ACM certificate that is in use until the
+// next maintenance window, zero when the reimport may happen now or there is no window. The window is
+// ignored when the ACM certificate expires within the override, DefaultMaintenanceWindowOverride when
+// zero. Errors are those of ACM.
+func deferReimport(window *maintenanceWindow, override time.Duration, acmService aws2.IAcmService, certificateArn *string, now time.Time) (time.Duration, error) {
+	if window == nil {
+		return 0, nil
+	}
+	next := window.next(now)
+	if next.IsZero() {
+		return 0, nil
+	}
+
+	description, err := acmService.DescribeCertificate(&acm.DescribeCertificateInput{CertificateArn: certificateArn})
+	if err != nil {
+		return 0, err
+	}
+	if description.Certificate == nil || len(description.Certificate.InUseBy) == 0 {
+		return 0, nil
+	}
+	if override == 0 {
+		override = DefaultMaintenanceWindowOverride
+	}
+	if notAfter := aws.TimeValue(description.Certificate.NotAfter); !notAfter.IsZero() && notAfter.Sub(now) < override {
+		return 0, nil
+	}
+	return next.Sub(now), nil
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	importsRefused = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acm_importer_imports_refused_total",
+		Help: "Number of imports refused by the domain policy",
+	}, []string{"namespace"})
+	importsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acm_importer_imports_total",
+		Help: "Number of certificates imported into ACM for the first time",
+	}, []string{"kind"})
+	reimportsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acm_importer_reimports_total",
+		Help: "Number of certificates reimported into an existing ACM certificate",
+	}, []string{"kind"})
+	deletesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acm_importer_deletes_total",
+		Help: "Number of certificates deleted from ACM",
+	}, []string{"kind"})
+	managedCertificatesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "acm_importer_managed_certificates",
+		Help: "Number of Certificates managed by the controller",
+	})
+	certificateNotAfter = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "acm_importer_certificate_not_after_seconds",
+		Help: "Expiry of the certificate held in ACM, in seconds since the epoch",
+	}, []string{"kind", "namespace", "name"})
+
+	duplicateCertificates = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "acm_importer_duplicate_certificates_total",
+		Help: "Number of ACM certificates found carrying the same cert-id as another",
+	})
+
+	pausedCertificatesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "acm_importer_paused_certificates",
+		Help: "Number of Certificates whose syncing with ACM is paused",
+	})
+	untaggedCertificatesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "acm_importer_untagged_certificates",
+		Help: "Number of reimported ACM certificates whose tags could not be updated",
+	})
+	expiringCertificatesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "acm_importer_expiring_certificates",
+		Help: "Number of Certificates whose ACM copy expires within each threshold, counted under the smallest one",
+	}, []string{"threshold"})
+	unimportedRenewalsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "acm_importer_unimported_renewals",
+		Help: "Number of expiring Certificates whose Secret holds a renewed certificate that was not imported",
+	})
+
+	managedCertificates      = map[string]bool{}
+	managedCertificatesMutex = &sync.Mutex{}
+	pausedCertificates       = map[string]bool{}
+	pausedCertificatesMutex  = &sync.Mutex{}
+	// untaggedCertificates are the ARNs of reimported certificates whose tags could not be updated
+	untaggedCertificates      = map[string]bool{}
+	untaggedCertificatesMutex = &sync.Mutex{}
+	// expiringCertificates are the smallest expiry thresholds crossed by Certificates, by cert-id
+	expiringCertificates      = map[string]time.Duration{}
+	expiringCertificatesMutex = &sync.Mutex{}
+	unimportedRenewals        = map[string]bool{}
+	unimportedRenewalsMutex   = &sync.Mutex{}
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		importsRefused,
+		importsTotal,
+		reimportsTotal,
+		deletesTotal,
+		managedCertificatesGauge,
+		certificateNotAfter,
+		duplicateCertificates,
+		untaggedCertificatesGauge,
+		pausedCertificatesGauge,
+		expiringCertificatesGauge,
+		unimportedRenewalsGauge,
+	)
+}
+
+// setManaged records whether the Certificate with the given cert-id is managed
+func setManaged(certId string, managed bool) {
+	managedCertificatesMutex.Lock()
+	defer managedCertificatesMutex.Unlock()
+	if managed {
+		managedCertificates[certId] = true
+	} else {
+		delete(managedCertificates, certId)
+	}
+	managedCertificatesGauge.Set(float64(len(managedCertificates)))
+}
+
+// setPaused records whether syncing the Certificate with the given cert-id is paused, and returns
+// whether that changed
+func setPaused(certId string, paused bool) bool {
+	pausedCertificatesMutex.Lock()
+	defer pausedCertificatesMutex.Unlock()
+	if pausedCertificates[certId] == paused {
+		return false
+	}
+	if paused {
+		pausedCertificates[certId] = true
+	} else {
+		delete(pausedCertificates, certId)
+	}
+	pausedCertificatesGauge.Set(float64(len(pausedCertificates)))
+	return true
+}
+
+// setUntagged records whether the tags of the ACM certificate with the given ARN could not be updated
+func setUntagged(certificateArn string, untagged bool) {
+	untaggedCertificatesMutex.Lock()
+	defer untaggedCertificatesMutex.Unlock()
+	if untagged {
+		untaggedCertificates[certificateArn] = true
+	} else {
+		delete(untaggedCertificates, certificateArn)
+	}
+	untaggedCertificatesGauge.Set(float64(len(untaggedCertificates)))
+}
+
+// setNotAfter records the expiry of the certificate in ACM, removing it when notAfter is nil
+func setNotAfter(kind string, namespace string, name string, notAfter *time.Time) {
+	if notAfter == nil {
+		certificateNotAfter.DeleteLabelValues(kind, namespace, name)
+		return
+	}
+	certificateNotAfter.WithLabelValues(kind, namespace, name).Set(float64(notAfter.Unix()))
+}
+
+// setCertificateNotAfter records the expiry of the ACM copy of the Certificate with the given cert-id
+func setCertificateNotAfter(certId string, notAfter *time.Time) {
+	parts := strings.SplitN(certId, "/", 2)
+	if len(parts) != 2 {
+		return
+	}
+	setNotAfter("Certificate", parts[0], parts[1], notAfter)
+}
+
+// setExpiring records the smallest expiry threshold crossed by the Certificate with the given cert-id, zero
+// for none, and returns the one previously recorded
+func setExpiring(certId string, threshold time.Duration) time.Duration {
+	expiringCertificatesMutex.Lock()
+	defer expiringCertificatesMutex.Unlock()
+	previous := expiringCertificates[certId]
+	if previous == threshold {
+		return previous
+	}
+	if previous != 0 {
+		expiringCertificatesGauge.WithLabelValues(formatThreshold(previous)).Dec()
+	}
+	if threshold != 0 {
+		expiringCertificates[certId] = threshold
+		expiringCertificatesGauge.WithLabelValues(formatThreshold(threshold)).Inc()
+	} else {
+		delete(expiringCertificates, certId)
+	}
+	return previous
+}
+
+// setUnimportedRenewal records whether the Secret of the Certificate with the given cert-id holds a renewal
+// that was not imported, and returns whether that was not recorded before
+func setUnimportedRenewal(certId string, unimported bool) bool {
+	unimportedRenewalsMutex.Lock()
+	defer unimportedRenewalsMutex.Unlock()
+	previous := unimportedRenewals[certId]
+	if unimported {
+		unimportedRenewals[certId] = true
+	} else {
+		delete(unimportedRenewals, certId)
+	}
+	unimportedRenewalsGauge.Set(float64(len(unimportedRenewals)))
+	return unimported && !previous
+}
+
+// forgetExpiry removes the expiry state of the Certificate with the given cert-id
+func forgetExpiry(certId string) {
+	setExpiring(certId, 0)
+	setUnimportedRenewal(certId, false)
+}
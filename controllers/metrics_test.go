@@ -0,0 +1,96 @@
+package controllers_test
+
+import (
+	aws2 "github.com/aws/aws-sdk-go/aws"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmetav1 "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"testing"
+	"time"
+)
+
+// gaugeValue returns the value of the gauge with the given name and labels from the controller-runtime registry
+func gaugeValue(t *testing.T, name string, labels map[string]string) (float64, bool) {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			matched := 0
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] == label.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(labels) {
+				return metric.GetGauge().GetValue(), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func TestCertificateNotAfterMetric(t *testing.T) {
+	basicCert := cmapiv1.Certificate{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/import-to-acm": "true",
+			},
+			Name:      "expiring",
+			Namespace: "foo",
+		},
+		Spec: cmapiv1.CertificateSpec{
+			SecretName: "secret",
+		},
+		Status: cmapiv1.CertificateStatus{
+			Revision: aws2.Int(1),
+			Conditions: []cmapiv1.CertificateCondition{
+				{
+					Type:   cmapiv1.CertificateConditionReady,
+					Status: cmmetav1.ConditionTrue,
+				},
+			},
+		},
+	}
+
+	notAfter := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	basicSecret := newTLSSecret(t, "foo", "secret", time.Now(), notAfter, "example.com")
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	cmapiv1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, &basicCert, basicSecret)
+	controller := controllers.CertificateReconciler{
+		Client:     client,
+		Cache:      make(map[string]*controllers.AcmCertificate),
+		AcmService: &MockService{},
+		APIReader:  client,
+	}
+
+	controller.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "expiring",
+	}})
+
+	value, ok := gaugeValue(t, "acm_importer_certificate_not_after_seconds", map[string]string{
+		"kind":      "Certificate",
+		"namespace": "foo",
+		"name":      "expiring",
+	})
+	if !ok {
+		t.Fatal("Missing NotAfter metric")
+	}
+	if int64(value) != notAfter.Unix() {
+		t.Errorf("Incorrect NotAfter metric %v", value)
+	}
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// migrateFromAnnotation points a Certificate at the cert-id or ARN of the ACM certificate it takes over,
+// for example after being recreated under a new name
+var migrateFromAnnotation = "legalzoom.com/acm-migrate-from"
+
+// migratedCertificate returns the ACM certificate a Certificate that is not in the cache migrates from,
+// and the cert-id it was imported for. Certificates only migrate from Certificates in their own
+// namespace, so that they cannot take over, and release, those of other teams.
+func (r *CertificateReconciler) migratedCertificate(certificate *cmapiv1.Certificate) (*AcmCertificate, string, error) {
+	migrateFrom := certificate.ObjectMeta.Annotations[migrateFromAnnotation]
+	if strings.HasPrefix(migrateFrom, "arn:") {
+		entry, err := r.adoptCertificate(migrateFrom)
+		if err != nil {
+			return nil, "", err
+		}
+		previousId, ok := tagValue(entry.Tags, certIdAnnotation)
+		if !ok {
+			return nil, "", fmt.Errorf("certificate %s has no %s tag; set %s and %s to adopt it",
+				migrateFrom, certIdAnnotation, certificateArnAnnotation, adoptAnnotation)
+		}
+		if !sameNamespace(certificate, previousId) {
+			return nil, "", fmt.Errorf("certificate %s belongs to %s in another namespace", migrateFrom, previousId)
+		}
+		return entry, previousId, nil
+	}
+
+	parts := strings.SplitN(migrateFrom, "/", 2)
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("%s is neither a namespace/name nor an ARN", migrateFrom)
+	}
+	if !sameNamespace(certificate, migrateFrom) {
+		return nil, "", fmt.Errorf("cannot migrate from %s in another namespace", migrateFrom)
+	}
+	mutex.RLock()
+	entry := r.Cache[migrateFrom]
+	mutex.RUnlock()
+	if entry == nil {
+		return nil, "", fmt.Errorf("no ACM certificate found for %s", migrateFrom)
+	}
+	return entry, migrateFrom, nil
+}
+
+// sameNamespace reports whether the cert-id names a Certificate in the namespace of certificate
+func sameNamespace(certificate *cmapiv1.Certificate, certId string) bool {
+	return strings.HasPrefix(certId, certificate.Namespace+"/")
+}
+
+// releasePrevious stops the Certificate with the given cert-id from being managed after its ACM
+// certificate was migrated, so that it neither imports a new certificate nor deletes the migrated one
+func (r *CertificateReconciler) releasePrevious(previousId string) error {
+	parts := strings.SplitN(previousId, "/", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	var previous cmapiv1.Certificate
+	if err := r.Get(context.Background(), types.NamespacedName{Namespace: parts[0], Name: parts[1]}, &previous); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if previous.Annotations == nil {
+		previous.Annotations = map[string]string{}
+	}
+	previous.Annotations[importToAcmAnnotation] = "false"
+	previous.Annotations[deletionPolicyAnnotation] = string(acmv1alpha1.DeletionPolicyRetain)
+	return r.Update(context.Background(), &previous)
+}
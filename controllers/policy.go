@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sort"
+	"strings"
+)
+
+// +kubebuilder:rbac:groups=acm.legalzoom.com,resources=acmimportpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+var (
+	importToAcmAnnotation    = "legalzoom.com/import-to-acm"
+	regionAnnotation         = "legalzoom.com/acm-region"
+	tagsAnnotation           = "legalzoom.com/acm-tags"
+	deletionPolicyAnnotation = "legalzoom.com/acm-deletion-policy"
+	// effectiveDeletionPolicyAnnotation records the deletion policy a managed Certificate was last
+	// reconciled with, so that it still applies once no AcmImportPolicy selects the Certificate
+	effectiveDeletionPolicyAnnotation = "legalzoom.com/acm-effective-deletion-policy"
+	// reservedTagPrefix is the prefix of the tags the controller identifies its certificates by, which
+	// AcmImportPolicies and annotations cannot set
+	reservedTagPrefix = "legalzoom.com/cert-importer/"
+)
+
+// importSettings are the settings a Certificate is imported with, taken from the first matching
+// AcmImportPolicy and overridden by the Certificate's own annotations
+type importSettings struct {
+	managed        bool
+	policy         string
+	region         string
+	tags           map[string]string
+	deletionPolicy acmv1alpha1.DeletionPolicy
+}
+
+// selectorMatches reports whether a label selector matches, treating an unset selector as matching everything
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(labels.Set(set)), nil
+}
+
+func issuerMatches(issuerRefs []acmv1alpha1.IssuerReference, certificate *cmapiv1.Certificate) bool {
+	if len(issuerRefs) == 0 {
+		return true
+	}
+	for _, issuerRef := range issuerRefs {
+		if issuerRef.Name != certificate.Spec.IssuerRef.Name {
+			continue
+		}
+		if issuerRef.Kind != "" && issuerRef.Kind != issuerKind(certificate.Spec.IssuerRef) {
+			continue
+		}
+		if issuerRef.Group != "" && issuerRef.Group != certificate.Spec.IssuerRef.Group {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// matchingPolicy returns the first AcmImportPolicy by name that selects the Certificate
+func (r *CertificateReconciler) matchingPolicy(certificate *cmapiv1.Certificate) (*acmv1alpha1.AcmImportPolicy, error) {
+	ctx := context.Background()
+
+	var policies acmv1alpha1.AcmImportPolicyList
+	if err := r.List(ctx, &policies); err != nil {
+		zap.S().Errorw("Failed to list AcmImportPolicies", "error", err)
+		return nil, err
+	}
+	if len(policies.Items) == 0 {
+		return nil, nil
+	}
+	sort.Slice(policies.Items, func(i, j int) bool {
+		return policies.Items[i].Name < policies.Items[j].Name
+	})
+
+	var namespace v1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: certificate.Namespace}, &namespace); err != nil {
+		zap.S().Errorw("Failed to get namespace", "namespace", certificate.Namespace, "error", err)
+		return nil, err
+	}
+
+	// An invalid selector fails the lookup rather than not matching, since treating the Certificates it
+	// was meant to select as unmanaged would delete their ACM certificates
+	for i, policy := range policies.Items {
+		namespaceMatches, err := selectorMatches(policy.Spec.NamespaceSelector, namespace.Labels)
+		if err != nil {
+			zap.S().Errorw("Invalid namespace selector in AcmImportPolicy", "policy", policy.Name, "error", err)
+			return nil, fmt.Errorf("invalid namespaceSelector in AcmImportPolicy %s: %v", policy.Name, err)
+		}
+		certificateMatches, err := selectorMatches(policy.Spec.Selector, certificate.Labels)
+		if err != nil {
+			zap.S().Errorw("Invalid label selector in AcmImportPolicy", "policy", policy.Name, "error", err)
+			return nil, fmt.Errorf("invalid selector in AcmImportPolicy %s: %v", policy.Name, err)
+		}
+		if namespaceMatches && certificateMatches && issuerMatches(policy.Spec.IssuerRefs, certificate) {
+			return &policies.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// parseTags parses a comma separated list of key=value pairs
+func parseTags(value string) map[string]string {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) == 2 && parts[0] != "" {
+			tags[parts[0]] = parts[1]
+		}
+	}
+	return tags
+}
+
+// userTags returns the tags set by source without the reserved tags, which are dropped with a warning
+func userTags(tags map[string]string, source string) map[string]string {
+	allowed := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if strings.HasPrefix(key, reservedTagPrefix) {
+			zap.S().Warnw("Ignoring reserved tag", "source", source, "tag", key)
+			continue
+		}
+		allowed[key] = value
+	}
+	return allowed
+}
+
+// resolveImportSettings returns the settings the Certificate is imported with. An error is returned
+// when the AcmImportPolicies could not be read or are invalid, since the Certificate may or may not be
+// managed.
+func (r *CertificateReconciler) resolveImportSettings(certificate *cmapiv1.Certificate) (importSettings, error) {
+	settings := importSettings{
+		tags:           map[string]string{},
+		deletionPolicy: acmv1alpha1.DeletionPolicyDelete,
+	}
+
+	switch certificate.Annotations[importToAcmAnnotation] {
+	case "true":
+		settings.managed = true
+	case "false":
+		return settings, nil
+	default:
+		policy, err := r.matchingPolicy(certificate)
+		if err != nil {
+			return settings, err
+		}
+		if policy == nil {
+			return settings, nil
+		}
+		settings.managed = true
+		settings.policy = policy.Name
+		settings.region = policy.Spec.Region
+		for key, value := range userTags(policy.Spec.Tags, "AcmImportPolicy "+policy.Name) {
+			settings.tags[key] = value
+		}
+		if policy.Spec.DeletionPolicy != "" {
+			settings.deletionPolicy = policy.Spec.DeletionPolicy
+		}
+	}
+
+	if region := certificate.Annotations[regionAnnotation]; region != "" {
+		settings.region = region
+	}
+	for key, value := range userTags(parseTags(certificate.Annotations[tagsAnnotation]), "Certificate "+certificate.Namespace+"/"+certificate.Name) {
+		settings.tags[key] = value
+	}
+	if policy, ok := deletionPolicyOverride(certificate); ok {
+		settings.deletionPolicy = policy
+	}
+	return settings, nil
+}
+
+// deletionPolicyOverride returns the deletion policy set by the Certificate's annotation, if valid
+func deletionPolicyOverride(certificate *cmapiv1.Certificate) (acmv1alpha1.DeletionPolicy, bool) {
+	return parseDeletionPolicy(certificate.Annotations[deletionPolicyAnnotation])
+}
+
+// unmanagedDeletionPolicy returns the deletion policy of a Certificate that is no longer managed: its
+// annotation's, else the one recorded while it was managed, else Delete
+func unmanagedDeletionPolicy(certificate *cmapiv1.Certificate) acmv1alpha1.DeletionPolicy {
+	if policy, ok := deletionPolicyOverride(certificate); ok {
+		return policy
+	}
+	if policy, ok := parseDeletionPolicy(certificate.Annotations[effectiveDeletionPolicyAnnotation]); ok {
+		return policy
+	}
+	return acmv1alpha1.DeletionPolicyDelete
+}
+
+func parseDeletionPolicy(value string) (acmv1alpha1.DeletionPolicy, bool) {
+	switch policy := acmv1alpha1.DeletionPolicy(value); policy {
+	case acmv1alpha1.DeletionPolicyDelete, acmv1alpha1.DeletionPolicyRetain:
+		return policy, true
+	}
+	return "", false
+}
+
+// mergeTags returns the tag list with the given tags added, replacing existing tags with the same keys
+func mergeTags(tags []*acm.Tag, extra map[string]string) []*acm.Tag {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	merged := make([]*acm.Tag, 0, len(tags)+len(keys))
+	for _, tag := range tags {
+		if _, ok := extra[*tag.Key]; !ok {
+			merged = append(merged, tag)
+		}
+	}
+	for _, key := range keys {
+		merged = append(merged, &acm.Tag{Key: aws.String(key), Value: aws.String(extra[key])})
+	}
+	return merged
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	"math/rand"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"time"
+)
+
+var (
+	// throttledRequeueAfter is how long after being throttled by ACM a request is retried, plus up to
+	// half as long again so that throttled Certificates do not retry together
+	throttledRequeueAfter = time.Minute
+	// quotaRequeueAfter is how long after reaching an ACM quota a request is retried
+	quotaRequeueAfter = time.Hour
+	// permanentRequeueAfter is how long after failing permanently a request is retried, in case it was
+	// fixed outside of the cluster, for example by granting the controller a missing permission
+	permanentRequeueAfter = 12 * time.Hour
+)
+
+// requeueForError returns what Reconcile returns after a call to ACM failed with err. Retryable errors are
+// returned for controller-runtime to retry with its backoff. Throttling and quota errors are retried after
+// a delay instead, and permanent errors, which are surfaced in events and the sync status, only rarely.
+func requeueForError(err error) (ctrl.Result, error) {
+	switch aws2.Classify(err) {
+	case aws2.ErrorClassThrottled:
+		jitter := time.Duration(rand.Int63n(int64(throttledRequeueAfter / 2)))
+		return ctrl.Result{RequeueAfter: throttledRequeueAfter + jitter}, nil
+	case aws2.ErrorClassQuota:
+		return ctrl.Result{RequeueAfter: quotaRequeueAfter}, nil
+	case aws2.ErrorClassPermanent:
+		return ctrl.Result{RequeueAfter: permanentRequeueAfter}, nil
+	default:
+		return ctrl.Result{}, err
+	}
+}
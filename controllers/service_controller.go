@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"time"
+)
+
+// ServiceReconciler keeps the NLB ssl-cert annotation of LoadBalancer Services
+// pointed at the ACM ARN of the Certificate they reference
+type ServiceReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Cache    map[string]*AcmCertificate
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;update;patch
+
+var (
+	serviceCertificateAnnotation = "legalzoom.com/acm-certificate"
+	serviceSslCertAnnotation     = "service.beta.kubernetes.io/aws-load-balancer-ssl-cert"
+	// serviceRequeueAfter is how often a Service is checked while its Certificate has not been imported
+	serviceRequeueAfter = time.Minute
+)
+
+func (r *ServiceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	var service v1.Service
+	if err := r.Get(ctx, req.NamespacedName, &service); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	certificateName := service.Annotations[serviceCertificateAnnotation]
+	if certificateName == "" || service.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return ctrl.Result{}, nil
+	}
+
+	certId := types.NamespacedName{Namespace: service.Namespace, Name: certificateName}.String()
+	mutex.RLock()
+	cachedEntry := r.Cache[certId]
+	mutex.RUnlock()
+	if cachedEntry == nil || cachedEntry.Summary == nil || cachedEntry.Summary.CertificateArn == nil {
+		// The cache also misses Certificates that are still to be imported, claimed or adopted, so the
+		// ARN is only removed once the Certificate is gone or explicitly unmanaged; an ARN left behind
+		// then would keep the load balancer on a certificate that was deleted or released
+		var certificate cmapiv1.Certificate
+		err := r.Get(ctx, types.NamespacedName{Namespace: service.Namespace, Name: certificateName}, &certificate)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if err == nil && certificate.Annotations[importToAcmAnnotation] != "false" {
+			zap.S().Infow("Certificate has not been imported yet",
+				"service", req.NamespacedName.String(),
+				"certificate", certId,
+			)
+			recordEvent(r.Recorder, &service, v1.EventTypeWarning, "CertificateNotImported",
+				"Certificate %s has no ACM certificate yet", certificateName)
+			return ctrl.Result{RequeueAfter: serviceRequeueAfter}, nil
+		}
+		recordEvent(r.Recorder, &service, v1.EventTypeWarning, "CertificateNotImported",
+			"Certificate %s does not exist or is not managed", certificateName)
+		if _, ok := service.Annotations[serviceSslCertAnnotation]; !ok {
+			return ctrl.Result{}, nil
+		}
+		zap.S().Infow("Removing ssl-cert annotation from service",
+			"service", req.NamespacedName.String(),
+			"certificate", certId,
+		)
+		delete(service.Annotations, serviceSslCertAnnotation)
+		return ctrl.Result{}, r.Update(ctx, &service)
+	}
+
+	arn := *cachedEntry.Summary.CertificateArn
+	if service.Annotations[serviceSslCertAnnotation] == arn {
+		return ctrl.Result{}, nil
+	}
+
+	zap.S().Infow("Setting ssl-cert annotation for service",
+		"service", req.NamespacedName.String(),
+		"certificate", certId,
+		"arn", arn,
+	)
+	service.Annotations[serviceSslCertAnnotation] = arn
+	if err := r.Update(ctx, &service); err != nil {
+		zap.S().Errorw("Error occurred updating service",
+			"service", req.NamespacedName.String(),
+			"error", err,
+		)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// servicesForCertificate maps a Certificate to the Services in its namespace that reference it
+func (r *ServiceReconciler) servicesForCertificate(obj handler.MapObject) []reconcile.Request {
+	var services v1.ServiceList
+	if err := r.List(context.Background(), &services, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		zap.S().Errorw("Failed to list services", "namespace", obj.Meta.GetNamespace(), "error", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, service := range services.Items {
+		if service.Annotations[serviceCertificateAnnotation] == obj.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Namespace: service.Namespace,
+				Name:      service.Name,
+			}})
+		}
+	}
+	return requests
+}
+
+func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.Service{}).
+		Watches(&source.Kind{Type: &cmapiv1.Certificate{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.servicesForCertificate),
+		}).
+		Complete(r)
+}
@@ -0,0 +1,138 @@
+package controllers_test
+
+import (
+	"context"
+	aws2 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/legalzoom/cert-manager-acm-importer/controllers"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+)
+
+func TestServiceSslCertAnnotation(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Annotations: map[string]string{
+				"legalzoom.com/acm-certificate":                         "bar",
+				"service.beta.kubernetes.io/aws-load-balancer-ssl-cert": "old-arn",
+			},
+			Name:      "nlb",
+			Namespace: "foo",
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	client := fake.NewFakeClientWithScheme(scheme, service)
+	controller := controllers.ServiceReconciler{
+		Client: client,
+		Cache:  make(map[string]*controllers.AcmCertificate),
+	}
+
+	controller.Cache["foo/bar"] = &controllers.AcmCertificate{
+		Summary: &acm.CertificateSummary{
+			CertificateArn: aws2.String("new-arn"),
+		},
+	}
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{
+		Namespace: "foo",
+		Name:      "nlb",
+	}}
+	if _, err := controller.Reconcile(request); err != nil {
+		t.Fatal(err)
+	}
+
+	var updated corev1.Service
+	if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Annotations["service.beta.kubernetes.io/aws-load-balancer-ssl-cert"] != "new-arn" {
+		t.Error("Incorrect ssl-cert annotation")
+	}
+}
+
+func TestServiceSslCertAnnotationRemoved(t *testing.T) {
+	cases := []struct {
+		name        string
+		certificate *cmapiv1.Certificate
+		removed     bool
+	}{
+		{"deleted certificate", nil, true},
+		{"unmanaged certificate", &cmapiv1.Certificate{ObjectMeta: v1.ObjectMeta{
+			Name:        "bar",
+			Namespace:   "foo",
+			Annotations: map[string]string{"legalzoom.com/import-to-acm": "false"},
+		}}, true},
+		{"certificate not imported yet", &cmapiv1.Certificate{ObjectMeta: v1.ObjectMeta{
+			Name:      "bar",
+			Namespace: "foo",
+		}}, false},
+	}
+
+	for _, c := range cases {
+		service := &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{
+				Annotations: map[string]string{
+					"legalzoom.com/acm-certificate":                         "bar",
+					"service.beta.kubernetes.io/aws-load-balancer-ssl-cert": "previous-arn",
+				},
+				Name:      "nlb",
+				Namespace: "foo",
+			},
+			Spec: corev1.ServiceSpec{
+				Type: corev1.ServiceTypeLoadBalancer,
+			},
+		}
+
+		scheme := runtime.NewScheme()
+		corev1.AddToScheme(scheme)
+		cmapiv1.AddToScheme(scheme)
+		objects := []runtime.Object{service}
+		if c.certificate != nil {
+			objects = append(objects, c.certificate)
+		}
+		client := fake.NewFakeClientWithScheme(scheme, objects...)
+		recorder := record.NewFakeRecorder(10)
+		controller := controllers.ServiceReconciler{
+			Client:   client,
+			Cache:    make(map[string]*controllers.AcmCertificate),
+			Recorder: recorder,
+		}
+
+		request := ctrl.Request{NamespacedName: types.NamespacedName{
+			Namespace: "foo",
+			Name:      "nlb",
+		}}
+		result, err := controller.Reconcile(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var updated corev1.Service
+		if err := client.Get(context.Background(), request.NamespacedName, &updated); err != nil {
+			t.Fatal(err)
+		}
+		_, kept := updated.Annotations["service.beta.kubernetes.io/aws-load-balancer-ssl-cert"]
+		if c.removed && kept {
+			t.Errorf("%s: expected the ssl-cert annotation to be removed", c.name)
+		}
+		if !c.removed && (!kept || result.RequeueAfter == 0) {
+			t.Errorf("%s: expected the ssl-cert annotation to be kept and the service requeued, got %v", c.name, result)
+		}
+		if event := <-recorder.Events; !strings.HasPrefix(event, "Warning CertificateNotImported") {
+			t.Errorf("%s: unexpected event %q", c.name, event)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"crypto/x509"
+	"github.com/aws/aws-sdk-go/aws"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	"strconv"
+	"time"
+)
+
+var (
+	lastSyncTimeAnnotation      = "legalzoom.com/acm-last-sync-time"
+	importedRevisionAnnotation  = "legalzoom.com/acm-imported-revision"
+	fingerprintAnnotation       = "legalzoom.com/acm-fingerprint"
+	notAfterAnnotation          = "legalzoom.com/acm-not-after"
+	lastErrorAnnotation         = "legalzoom.com/acm-last-error"
+	reimportRequestedAnnotation = "legalzoom.com/acm-reimport-requested-at"
+	untaggedAnnotation          = "legalzoom.com/acm-untagged"
+)
+
+// reimportRequested reports whether the Certificate requests a reimport that is newer than its last
+// sync. Requests are RFC 3339 timestamps; invalid timestamps are ignored. A request for a time in the
+// future is not due yet, and how long until it is due is returned instead.
+func reimportRequested(certificate *cmapiv1.Certificate, now time.Time) (bool, time.Duration) {
+	var lastSync *time.Time
+	if value, err := time.Parse(time.RFC3339, certificate.Annotations[lastSyncTimeAnnotation]); err == nil {
+		lastSync = &value
+	}
+	return reimportRequestedSince(certificate.Annotations, lastSync, now)
+}
+
+// reimportRequestedSince reports whether the annotations request a reimport that is newer than the last
+// sync, nil when unknown, like reimportRequested
+func reimportRequestedSince(annotations map[string]string, lastSync *time.Time, now time.Time) (bool, time.Duration) {
+	value := annotations[reimportRequestedAnnotation]
+	if value == "" {
+		return false, 0
+	}
+	requestedAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false, 0
+	}
+	if lastSync != nil && !requestedAt.After(*lastSync) {
+		return false, 0
+	}
+	if requestedAt.After(now) {
+		return false, requestedAt.Sub(now)
+	}
+	return true, 0
+}
+
+// setAnnotation sets an annotation on the Certificate, removing it when value is empty.
+// It returns whether the annotations changed.
+func setAnnotation(certificate *cmapiv1.Certificate, key string, value string) bool {
+	if certificate.Annotations[key] == value {
+		return false
+	}
+	if value == "" {
+		delete(certificate.Annotations, key)
+		return true
+	}
+	if certificate.Annotations == nil {
+		certificate.Annotations = map[string]string{}
+	}
+	certificate.Annotations[key] = value
+	return true
+}
+
+// syncRecord is a successful import, kept in the cache so that it is recorded on the Certificate even
+// when updating the Certificate failed right after the import
+type syncRecord struct {
+	revision int
+	// leaf is nil when the imported certificate could not be parsed
+	leaf *x509.Certificate
+	at   time.Time
+}
+
+// syncPending reports whether the Certificate's annotations do not record the sync yet
+func syncPending(certificate *cmapiv1.Certificate, record *syncRecord) bool {
+	if record == nil {
+		return false
+	}
+	lastSync, err := time.Parse(time.RFC3339, certificate.Annotations[lastSyncTimeAnnotation])
+	return err != nil || lastSync.Before(record.at.Truncate(time.Second))
+}
+
+// recordSyncSuccess records a successful import of revision on the Certificate's annotations, which
+// also marks requested reimports up to now as handled. leaf is nil when the imported certificate could
+// not be parsed.
+func recordSyncSuccess(certificate *cmapiv1.Certificate, revision int, leaf *x509.Certificate, now time.Time) {
+	setAnnotation(certificate, lastSyncTimeAnnotation, now.UTC().Format(time.RFC3339))
+	setAnnotation(certificate, importedRevisionAnnotation, strconv.Itoa(revision))
+	setAnnotation(certificate, lastErrorAnnotation, "")
+	setAnnotation(certificate, untaggedAnnotation, "")
+	if leaf != nil {
+		setAnnotation(certificate, fingerprintAnnotation, Fingerprint(leaf))
+		setAnnotation(certificate, notAfterAnnotation, leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+}
+
+// recordSyncError records the error of a failed sync on the Certificate's annotations, along with the
+// ARN of a certificate that was reimported but left with its previous tags, which is kept until the
+// next successful import. It returns whether the annotations changed.
+func recordSyncError(certificate *cmapiv1.Certificate, err error) bool {
+	changed := setAnnotation(certificate, lastErrorAnnotation, syncErrorMessage(err))
+	if taggingErr, ok := err.(*aws2.TaggingError); ok {
+		changed = setAnnotation(certificate, untaggedAnnotation, aws.StringValue(taggingErr.CertificateArn)) || changed
+	}
+	return changed
+}
+
+// syncErrorMessage describes an error for the last-error annotation. Errors from AWS are described by
+// their class and code only, since their messages carry a request id that differs on every attempt, and
+// updating the Certificate with each of them would trigger a reconcile ahead of the requeue delay.
+func syncErrorMessage(err error) string {
+	if code := aws2.ErrorCode(err); code != "Unknown" {
+		return string(aws2.Classify(err)) + ": " + code
+	}
+	return err.Error()
+}
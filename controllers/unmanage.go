@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
+	aws2 "github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// releaseCertificate removes the controller's tags from a retained ACM certificate, so that it is no
+// longer taken for the Certificate it was imported for. A certificate that no longer exists counts as
+// released.
+func releaseCertificate(acmService aws2.IAcmService, certificateArn *string) error {
+	_, err := acmService.RemoveTagsFromCertificate(&acm.RemoveTagsFromCertificateInput{
+		CertificateArn: certificateArn,
+		Tags: []*acm.Tag{
+			{Key: aws.String(certIdAnnotation)},
+			{Key: aws.String(certRevisionAnnotation)},
+			{Key: aws.String(clusterIdTag)},
+		},
+	})
+	if _, ok := err.(*acm.ResourceNotFoundException); ok {
+		return nil
+	}
+	return err
+}
+
+// unmanage cleans up after a Certificate that carries the finalizer but is no longer managed, because
+// its import-to-acm annotation was removed or no AcmImportPolicy selects it any more. Depending on its
+// deletion policy, Delete by default, the ACM certificate is deleted or released. The ARN and sync status
+// annotations and the finalizer are removed.
+func (r *CertificateReconciler) unmanage(req ctrl.Request, certificate *cmapiv1.Certificate) (ctrl.Result, error) {
+	deletionPolicy := unmanagedDeletionPolicy(certificate)
+
+	mutex.RLock()
+	cachedEntry := r.Cache[req.NamespacedName.String()]
+	mutex.RUnlock()
+
+	if cachedEntry != nil {
+		certificateArn := aws.StringValue(cachedEntry.Summary.CertificateArn)
+		acmService, err := r.serviceFor(certificate, cachedEntry.Region)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if deletionPolicy == acmv1alpha1.DeletionPolicyRetain {
+			zap.S().Infow("Releasing certificate in ACM", "certificate", req.NamespacedName.String(), "arn", certificateArn)
+			if err := releaseCertificate(acmService, cachedEntry.Summary.CertificateArn); err != nil {
+				zap.S().Errorw("Failed to release certificate in ACM",
+					"certificate", req.NamespacedName.String(),
+					"arn", certificateArn,
+					"class", aws2.Classify(err),
+					"error", err,
+				)
+				recordEvent(r.Recorder, certificate, v1.EventTypeWarning, "ReleaseFailed",
+					"Failed to release certificate %s in ACM: %s: %v", certificateArn, aws2.ErrorCode(err), err)
+				return requeueForError(err)
+			}
+			if r.dryRun(certificate) {
+				recordEvent(r.Recorder, certificate, v1.EventTypeNormal, "DryRun",
+					"No longer managed; would release certificate %s in ACM", certificateArn)
+				return r.unmanageDryRun(certificate)
+			}
+			recordEvent(r.Recorder, certificate, v1.EventTypeNormal, "Released",
+				"No longer managed; released certificate %s in ACM", certificateArn)
+		} else {
+			zap.S().Infow("Deleting unmanaged certificate in ACM", "certificate", req.NamespacedName.String(), "arn", certificateArn)
+			_, err = acmService.DeleteCertificate(&acm.DeleteCertificateInput{
+				CertificateArn: cachedEntry.Summary.CertificateArn,
+			})
+			if err != nil {
+				if _, ok := err.(*acm.ResourceNotFoundException); !ok {
+					zap.S().Errorw("Failed to delete certificate in ACM",
+						"certificate", req.NamespacedName.String(),
+						"arn", certificateArn,
+						"class", aws2.Classify(err),
+						"error", err,
+					)
+					recordEvent(r.Recorder, certificate, v1.EventTypeWarning, "DeleteFailed",
+						"Failed to delete certificate %s from ACM: %s: %v", certificateArn, aws2.ErrorCode(err), err)
+					return requeueForError(err)
+				}
+			} else if r.dryRun(certificate) {
+				recordEvent(r.Recorder, certificate, v1.EventTypeNormal, "DryRun",
+					"No longer managed; would delete certificate %s from ACM", certificateArn)
+				return r.unmanageDryRun(certificate)
+			} else {
+				deletesTotal.WithLabelValues("Certificate").Inc()
+			}
+			recordEvent(r.Recorder, certificate, v1.EventTypeNormal, "Deleted",
+				"No longer managed; deleted certificate %s from ACM", certificateArn)
+		}
+
+		mutex.Lock()
+		r.Cache[req.NamespacedName.String()] = nil
+		mutex.Unlock()
+		setCertificateNotAfter(req.NamespacedName.String(), nil)
+		forgetExpiry(req.NamespacedName.String())
+	}
+	setSkipReason(req.NamespacedName.String(), "")
+
+	for _, annotation := range []string{
+		certificateArnAnnotation,
+		lastSyncTimeAnnotation,
+		importedRevisionAnnotation,
+		fingerprintAnnotation,
+		notAfterAnnotation,
+		lastErrorAnnotation,
+		effectiveDeletionPolicyAnnotation,
+	} {
+		delete(certificate.Annotations, annotation)
+	}
+	certificate.ObjectMeta.Finalizers = removeString(certificate.ObjectMeta.Finalizers, finalizer)
+	if err := r.Update(context.Background(), certificate); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// unmanageDryRun leaves the Certificate unchanged in dry-run mode, so that the plan is repeated until
+// dry-run mode is disabled, unless the Certificate is being deleted and only the finalizer holds it
+func (r *CertificateReconciler) unmanageDryRun(certificate *cmapiv1.Certificate) (ctrl.Result, error) {
+	if certificate.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	certificate.ObjectMeta.Finalizers = removeString(certificate.ObjectMeta.Finalizers, finalizer)
+	return ctrl.Result{}, r.Update(context.Background(), certificate)
+}
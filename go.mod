@@ -6,9 +6,13 @@ require (
 	github.com/aws/aws-sdk-go v1.33.0
 	github.com/go-logr/logr v0.2.1-0.20200730175230-ee2de8da5be6
 	github.com/jetstack/cert-manager v1.0.3
+	github.com/prometheus/client_golang v1.7.1
+	github.com/robfig/cron/v3 v3.0.1
 	go.uber.org/zap v1.10.0
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	k8s.io/api v0.19.0
 	k8s.io/apimachinery v0.19.0
 	k8s.io/client-go v0.19.0
 	sigs.k8s.io/controller-runtime v0.6.2
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -18,20 +18,25 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/acm"
 	"github.com/legalzoom/cert-manager-acm-importer/pkg/aws"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"os"
+	"strings"
+	"time"
 
 	cmapiv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	acmv1alpha1 "github.com/legalzoom/cert-manager-acm-importer/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/legalzoom/cert-manager-acm-importer/controllers"
 	// +kubebuilder:scaffold:imports
@@ -47,6 +52,7 @@ func init() {
 
 	_ = appsv1.AddToScheme(scheme)
 	_ = cmapiv1.AddToScheme(scheme)
+	_ = acmv1alpha1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -62,12 +68,139 @@ func initZapLog() *zap.Logger {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var enableGatewayAPI bool
+	var gatewayArnAnnotation string
+	var assumeRoleName string
+	var regions string
+	var domainPolicyFile string
+	var clusterId string
+	var claimUntagged bool
+	var deleteDuplicates bool
+	var dryRun bool
+	var maintenanceWindowOverride time.Duration
+	var expiryThresholds string
+	var acmRateLimits string
+	var acmBreakerThreshold int
+	var acmBreakerCooldown time.Duration
+	var serveInventory bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&serveInventory, "serve-inventory", false,
+		"Serve the inventory of ACM certificates on the metrics address at /inventory. The metrics address is not authenticated, "+
+			"so only enable it when the address can only be reached by those allowed to see the inventory.")
+	flag.BoolVar(&enableGatewayAPI, "enable-gateway-api", false,
+		"Enable writing certificate ARNs to Gateway API Gateways that reference managed certificates.")
+	flag.StringVar(&gatewayArnAnnotation, "gateway-arn-annotation", controllers.DefaultGatewayArnAnnotation,
+		"The Gateway annotation that receives the ARNs of the certificates referenced by its listeners.")
+	flag.StringVar(&assumeRoleName, "assume-role-name", "cert-manager-acm-importer",
+		"The IAM role assumed when an AcmImport targets another AWS account.")
+	flag.StringVar(&regions, "regions", "",
+		"Comma separated list of regions, besides the default region, that certificates may be imported into.")
+	flag.StringVar(&domainPolicyFile, "domain-policy", "",
+		"Path to a YAML file restricting the DNS names and issuers each namespace may import into ACM.")
+	flag.StringVar(&clusterId, "cluster-id", "",
+		"Identifies this cluster in the tags of imported certificates, so that clusters sharing an AWS account ignore each other's certificates.")
+	flag.BoolVar(&claimUntagged, "claim-untagged", false,
+		"Take over certificates imported before --cluster-id was set. Only set it on one of the clusters sharing an AWS account.")
+	flag.BoolVar(&deleteDuplicates, "delete-duplicates", false,
+		"Delete ACM certificates found at startup carrying the same cert-id as the one kept, unless they are in use.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Only log and record events for the imports, tag changes and deletes that would be made in ACM.")
+	flag.DurationVar(&maintenanceWindowOverride, "maintenance-window-override", controllers.DefaultMaintenanceWindowOverride,
+		"Reimport certificates outside of their maintenance window when the ACM certificate expires within this duration.")
+	flag.StringVar(&expiryThresholds, "expiry-thresholds", "30d,14d,7d",
+		"Comma separated list of how long before the ACM copy of a certificate expires to warn about it and check for renewals that were not imported.")
+	flag.StringVar(&acmRateLimits, "acm-rate-limits", "",
+		"Comma separated list of operation=rate[:burst] overrides of the default per operation limits on ACM API calls per second, for example ImportCertificate=0.5.")
+	flag.IntVar(&acmBreakerThreshold, "acm-breaker-threshold", 5,
+		"Pause writes to ACM after this many have failed in a row with retryable, throttling or quota errors. 0 disables the circuit breaker.")
+	flag.DurationVar(&acmBreakerCooldown, "acm-breaker-cooldown", 5*time.Minute,
+		"How long writes to ACM are paused for once the circuit breaker opens.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [sync|list|gc|import|report [command flags]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	loggerMgr := initZapLog()
+	zap.ReplaceGlobals(loggerMgr)
+	defer loggerMgr.Sync() // flushes buffer, if any
+	sess := session.Must(session.NewSession())
+	acmClient := acm.New(sess)
+
+	rateLimits, err := aws.ParseRateLimits(acmRateLimits)
+	if err != nil {
+		setupLog.Error(err, "unable to parse ACM rate limits")
+		os.Exit(1)
+	}
+	serviceOptions := aws.ServiceOptions{
+		RateLimits:       rateLimits,
+		BreakerThreshold: acmBreakerThreshold,
+		BreakerCooldown:  acmBreakerCooldown,
+	}
+	AcmService := aws.NewAcmService(acmClient, "default", serviceOptions)
+	AcmServices := &aws.AcmServiceFactory{
+		Session:  sess,
+		Default:  AcmService,
+		RoleName: assumeRoleName,
+		Options:  serviceOptions,
+	}
+	var additionalRegions []string
+	if regions != "" {
+		additionalRegions = strings.Split(regions, ",")
+	}
+	var domainPolicy *controllers.DomainPolicy
+	if domainPolicyFile != "" {
+		var err error
+		if domainPolicy, err = controllers.LoadDomainPolicy(domainPolicyFile); err != nil {
+			setupLog.Error(err, "unable to load domain policy")
+			os.Exit(1)
+		}
+	}
+	thresholds, err := controllers.ParseExpiryThresholds(expiryThresholds)
+	if err != nil {
+		setupLog.Error(err, "unable to parse expiry thresholds")
+		os.Exit(1)
+	}
+	cache := make(map[string]*controllers.AcmCertificate)
+
+	if flag.NArg() > 0 {
+		command, ok := commands[flag.Arg(0)]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+			flag.Usage()
+			os.Exit(2)
+		}
+		k8sClient, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		reconciler := &controllers.CertificateReconciler{
+			Client:                    k8sClient,
+			APIReader:                 k8sClient,
+			Log:                       ctrl.Log.WithName("commands").WithName(flag.Arg(0)),
+			Scheme:                    scheme,
+			Cache:                     cache,
+			AcmService:                AcmService,
+			AcmServices:               AcmServices,
+			Regions:                   additionalRegions,
+			DomainPolicy:              domainPolicy,
+			ClusterId:                 clusterId,
+			ClaimUntagged:             claimUntagged,
+			DeleteDuplicates:          deleteDuplicates,
+			DryRun:                    dryRun,
+			MaintenanceWindowOverride: maintenanceWindowOverride,
+			ExpiryThresholds:          thresholds,
+		}
+		reconciler.InitializeCache()
+		status := command(reconciler, flag.Args()[1:])
+		loggerMgr.Sync()
+		os.Exit(status)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -79,25 +212,74 @@ func main() {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
-
-	loggerMgr := initZapLog()
-	zap.ReplaceGlobals(loggerMgr)
-	defer loggerMgr.Sync() // flushes buffer, if any
-	sess := session.Must(session.NewSession())
-	acmClient := acm.New(sess)
-
-	AcmService := &aws.AcmService{Client: acmClient}
-	if err = (&controllers.CertificateReconciler{
-		Client:     mgr.GetClient(),
-		APIReader:  mgr.GetAPIReader(),
-		Log:        ctrl.Log.WithName("controllers").WithName("Certificate"),
-		Scheme:     mgr.GetScheme(),
-		Cache:      make(map[string]*controllers.AcmCertificate),
-		AcmService: AcmService,
-	}).SetupWithManager(mgr); err != nil {
+	recorder := mgr.GetEventRecorderFor("cert-manager-acm-importer")
+	certificateReconciler := &controllers.CertificateReconciler{
+		Client:                    mgr.GetClient(),
+		APIReader:                 mgr.GetAPIReader(),
+		Log:                       ctrl.Log.WithName("controllers").WithName("Certificate"),
+		Scheme:                    mgr.GetScheme(),
+		Cache:                     cache,
+		AcmService:                AcmService,
+		AcmServices:               AcmServices,
+		Regions:                   additionalRegions,
+		DomainPolicy:              domainPolicy,
+		Recorder:                  recorder,
+		ClusterId:                 clusterId,
+		ClaimUntagged:             claimUntagged,
+		DeleteDuplicates:          deleteDuplicates,
+		DryRun:                    dryRun,
+		MaintenanceWindowOverride: maintenanceWindowOverride,
+		ExpiryThresholds:          thresholds,
+	}
+	if err = certificateReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Deployment")
 		os.Exit(1)
 	}
+	if serveInventory {
+		if err = mgr.AddMetricsExtraHandler("/inventory", certificateReconciler.InventoryHandler(controllers.DefaultInventoryCacheDuration)); err != nil {
+			setupLog.Error(err, "unable to add inventory handler")
+			os.Exit(1)
+		}
+	}
+	if err = (&controllers.ServiceReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Service"),
+		Scheme:   mgr.GetScheme(),
+		Cache:    cache,
+		Recorder: recorder,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Service")
+		os.Exit(1)
+	}
+	if err = (&controllers.AcmImportReconciler{
+		Client:                    mgr.GetClient(),
+		APIReader:                 mgr.GetAPIReader(),
+		Log:                       ctrl.Log.WithName("controllers").WithName("AcmImport"),
+		Scheme:                    mgr.GetScheme(),
+		AcmServices:               AcmServices,
+		DomainPolicy:              domainPolicy,
+		Recorder:                  recorder,
+		ClusterId:                 clusterId,
+		ClaimUntagged:             claimUntagged,
+		DryRun:                    dryRun,
+		MaintenanceWindowOverride: maintenanceWindowOverride,
+		ExpiryThresholds:          thresholds,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AcmImport")
+		os.Exit(1)
+	}
+	if enableGatewayAPI {
+		if err = (&controllers.GatewayReconciler{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("controllers").WithName("Gateway"),
+			Scheme:        mgr.GetScheme(),
+			Cache:         cache,
+			ArnAnnotation: gatewayArnAnnotation,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Gateway")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
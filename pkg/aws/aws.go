@@ -1,16 +1,117 @@
 package aws
 
 import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/acm"
+	"sync"
+	"time"
 )
 
 type IAcmService interface {
 	UpsertCertificate(input *acm.ImportCertificateInput) (*UpsertCertificateResponse, error)
 	DeleteCertificate(input *acm.DeleteCertificateInput) (*acm.DeleteCertificateOutput, error)
+	DescribeCertificate(input *acm.DescribeCertificateInput) (*acm.DescribeCertificateOutput, error)
+	ListCertificates(input *acm.ListCertificatesInput) (*acm.ListCertificatesOutput, error)
+	ListTagsForCertificate(input *acm.ListTagsForCertificateInput) (*acm.ListTagsForCertificateOutput, error)
+	RemoveTagsFromCertificate(input *acm.RemoveTagsFromCertificateInput) (*acm.RemoveTagsFromCertificateOutput, error)
 }
 
+// IAcmServiceFactory returns the ACM service for a region and account. Empty values select the
+// region and account of the controller's own credentials.
+type IAcmServiceFactory interface {
+	ServiceFor(region string, account string) (IAcmService, error)
+}
+
+// ErrorCode returns the AWS error code of an error returned by the SDK
+func ErrorCode(err error) string {
+	if taggingErr, ok := err.(*TaggingError); ok {
+		err = taggingErr.Err
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	if _, ok := err.(*CircuitOpenError); ok {
+		return "CircuitOpen"
+	}
+	return "Unknown"
+}
+
+// TaggingError is returned by UpsertCertificate when a certificate was reimported but could not be
+// tagged. The certificate keeps its previous tags.
+type TaggingError struct {
+	CertificateArn *string
+	Err            error
+}
+
+func (e *TaggingError) Error() string {
+	return fmt.Sprintf("imported certificate %s but failed to tag it: %v", aws.StringValue(e.CertificateArn), e.Err)
+}
+
+func (e *TaggingError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// tagAttempts is how often tagging a reimported certificate is attempted
+	tagAttempts = 3
+	// tagRetryDelay is the delay before the first retry, doubled for every further retry
+	tagRetryDelay = time.Second
+)
+
 type AcmService struct {
 	Client *acm.ACM
+	// Limiter limits the rate of calls to ACM, not at all when nil
+	Limiter *RateLimiter
+	// Breaker pauses writes to ACM after repeated failures, never when nil
+	Breaker *CircuitBreaker
+}
+
+// ServiceOptions configure the rate limiting and circuit breaking of an AcmService
+type ServiceOptions struct {
+	// RateLimits are the limits of each operation, none when nil
+	RateLimits RateLimits
+	// BreakerThreshold is how many writes in a row must fail to pause writes, never when zero
+	BreakerThreshold int
+	// BreakerCooldown is how long writes are paused for
+	BreakerCooldown time.Duration
+}
+
+// NewAcmService returns an AcmService with its own rate limiter and circuit breaker, name identifying it
+// in logs and metrics
+func NewAcmService(client *acm.ACM, name string, options ServiceOptions) *AcmService {
+	service := &AcmService{
+		Client: client,
+		Breaker: &CircuitBreaker{
+			Name:      name,
+			Threshold: options.BreakerThreshold,
+			Cooldown:  options.BreakerCooldown,
+		},
+	}
+	if options.RateLimits != nil {
+		service.Limiter = NewRateLimiter(options.RateLimits)
+	}
+	return service
+}
+
+// call makes the ACM API call f, waiting for the rate limiter and, for writes, checking the circuit breaker
+func (s *AcmService) call(operation string, write bool, f func() error) error {
+	if write {
+		if err := s.Breaker.Allow(operation); err != nil {
+			return err
+		}
+	}
+	s.Limiter.Wait(operation)
+	start := time.Now()
+	err := f()
+	observe(operation, start, err)
+	if write {
+		s.Breaker.Record(err)
+	}
+	return err
 }
 
 type UpsertCertificateResponse struct {
@@ -18,21 +119,43 @@ type UpsertCertificateResponse struct {
 	Tags           []*acm.Tag
 }
 
+// UpsertCertificate imports a certificate. New certificates are tagged as part of the import, so they
+// are never left untagged. ACM does not accept tags when reimporting, so reimported certificates are
+// tagged afterwards, retrying since tagging is idempotent; a *TaggingError is returned if that fails.
 func (s *AcmService) UpsertCertificate(input *acm.ImportCertificateInput) (*UpsertCertificateResponse, error) {
 	tags := input.Tags
-	input.Tags = nil
+	if input.CertificateArn != nil {
+		input.Tags = nil
+	}
 
-	response, err := s.Client.ImportCertificate(input)
+	var response *acm.ImportCertificateOutput
+	err := s.call("ImportCertificate", true, func() (err error) {
+		response, err = s.Client.ImportCertificate(input)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	_, err = s.Client.AddTagsToCertificate(&acm.AddTagsToCertificateInput{
-		CertificateArn: response.CertificateArn,
-		Tags:           tags,
-	})
 
-	if err != nil {
-		return nil, err
+	if input.CertificateArn != nil && len(tags) > 0 {
+		delay := tagRetryDelay
+		for attempt := 1; ; attempt++ {
+			err = s.call("AddTagsToCertificate", true, func() error {
+				_, err := s.Client.AddTagsToCertificate(&acm.AddTagsToCertificateInput{
+					CertificateArn: response.CertificateArn,
+					Tags:           tags,
+				})
+				return err
+			})
+			if err == nil {
+				break
+			}
+			if attempt == tagAttempts {
+				return nil, &TaggingError{CertificateArn: response.CertificateArn, Err: err}
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
 	}
 
 	return &UpsertCertificateResponse{
@@ -42,5 +165,92 @@ func (s *AcmService) UpsertCertificate(input *acm.ImportCertificateInput) (*Upse
 }
 
 func (s *AcmService) DeleteCertificate(input *acm.DeleteCertificateInput) (*acm.DeleteCertificateOutput, error) {
-	return s.Client.DeleteCertificate(input)
+	var output *acm.DeleteCertificateOutput
+	err := s.call("DeleteCertificate", true, func() (err error) {
+		output, err = s.Client.DeleteCertificate(input)
+		return err
+	})
+	return output, err
+}
+
+func (s *AcmService) DescribeCertificate(input *acm.DescribeCertificateInput) (*acm.DescribeCertificateOutput, error) {
+	var output *acm.DescribeCertificateOutput
+	err := s.call("DescribeCertificate", false, func() (err error) {
+		output, err = s.Client.DescribeCertificate(input)
+		return err
+	})
+	return output, err
+}
+
+func (s *AcmService) ListCertificates(input *acm.ListCertificatesInput) (*acm.ListCertificatesOutput, error) {
+	var output *acm.ListCertificatesOutput
+	err := s.call("ListCertificates", false, func() (err error) {
+		output, err = s.Client.ListCertificates(input)
+		return err
+	})
+	return output, err
+}
+
+func (s *AcmService) ListTagsForCertificate(input *acm.ListTagsForCertificateInput) (*acm.ListTagsForCertificateOutput, error) {
+	var output *acm.ListTagsForCertificateOutput
+	err := s.call("ListTagsForCertificate", false, func() (err error) {
+		output, err = s.Client.ListTagsForCertificate(input)
+		return err
+	})
+	return output, err
+}
+
+func (s *AcmService) RemoveTagsFromCertificate(input *acm.RemoveTagsFromCertificateInput) (*acm.RemoveTagsFromCertificateOutput, error) {
+	var output *acm.RemoveTagsFromCertificateOutput
+	err := s.call("RemoveTagsFromCertificate", true, func() (err error) {
+		output, err = s.Client.RemoveTagsFromCertificate(input)
+		return err
+	})
+	return output, err
+}
+
+// AcmServiceFactory creates one AcmService per region and account from a base session,
+// assuming RoleName in accounts other than the session's own
+type AcmServiceFactory struct {
+	Session  *session.Session
+	Default  IAcmService
+	RoleName string
+	// Options configure the rate limiter and circuit breaker of each service created
+	Options ServiceOptions
+
+	mutex    sync.Mutex
+	services map[string]IAcmService
+}
+
+func (f *AcmServiceFactory) ServiceFor(region string, account string) (IAcmService, error) {
+	if region == "" && account == "" && f.Default != nil {
+		return f.Default, nil
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := region + "/" + account
+	if service, ok := f.services[key]; ok {
+		return service, nil
+	}
+
+	config := aws.NewConfig()
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	if account != "" {
+		if f.RoleName == "" {
+			return nil, fmt.Errorf("cannot import into account %s without a role to assume", account)
+		}
+		roleArn := fmt.Sprintf("arn:aws:iam::%s:role/%s", account, f.RoleName)
+		config = config.WithCredentials(stscreds.NewCredentials(f.Session, roleArn))
+	}
+
+	service := NewAcmService(acm.New(f.Session, config), key, f.Options)
+	if f.services == nil {
+		f.services = map[string]IAcmService{}
+	}
+	f.services[key] = service
+	return service, nil
 }
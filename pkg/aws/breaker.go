@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// CircuitOpenError is returned instead of calling ACM while the circuit breaker pauses writes
+type CircuitOpenError struct {
+	Operation string
+	Until     time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("not calling %s: writes to ACM are paused after repeated failures until %s",
+		e.Operation, e.Until.UTC().Format(time.RFC3339))
+}
+
+// CircuitBreaker pauses writes to ACM for Cooldown once Threshold writes in a row have failed with
+// retryable, throttling or quota errors. After the cooldown a single write is let through; the breaker
+// closes when it succeeds and opens again when it fails. Permanent errors concern a single certificate,
+// so they neither open nor close the breaker.
+type CircuitBreaker struct {
+	// Name identifies the ACM service the breaker belongs to in logs and metrics
+	Name string
+	// Threshold is how many writes in a row must fail to open the breaker, never when zero
+	Threshold int
+	Cooldown  time.Duration
+
+	mutex    sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// Allow returns a *CircuitOpenError when a write may not be made now
+func (b *CircuitBreaker) Allow(operation string) error {
+	if b == nil || b.Threshold <= 0 {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failures < b.Threshold {
+		return nil
+	}
+	if until := b.openedAt.Add(b.Cooldown); time.Now().Before(until) || b.trial {
+		circuitBreakerRejections.WithLabelValues(b.Name, operation).Inc()
+		return &CircuitOpenError{Operation: operation, Until: until}
+	}
+	b.trial = true
+	return nil
+}
+
+// Record records the outcome of a write that was allowed
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil || b.Threshold <= 0 {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	wasOpen := b.failures >= b.Threshold
+	b.trial = false
+	if err == nil {
+		b.failures = 0
+		if wasOpen {
+			zap.S().Infow("Resuming writes to ACM", "service", b.Name)
+		}
+	} else if Classify(err) != ErrorClassPermanent {
+		b.failures++
+		if b.failures >= b.Threshold {
+			if !wasOpen {
+				zap.S().Warnw("Pausing writes to ACM after repeated failures",
+					"service", b.Name,
+					"failures", b.failures,
+					"cooldown", b.Cooldown.String(),
+					"error", err,
+				)
+			}
+			b.openedAt = time.Now()
+		}
+	}
+	if b.failures >= b.Threshold {
+		circuitBreakerOpen.WithLabelValues(b.Name).Set(1)
+	} else {
+		circuitBreakerOpen.WithLabelValues(b.Name).Set(0)
+	}
+}
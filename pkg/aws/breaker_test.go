@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := &CircuitBreaker{Name: "test", Threshold: 2, Cooldown: time.Hour}
+	throttled := awserr.New("ThrottlingException", "Rate exceeded", nil)
+
+	breaker.Record(throttled)
+	breaker.Record(awserr.New("ValidationException", "Invalid certificate chain", nil))
+	if err := breaker.Allow("ImportCertificate"); err != nil {
+		t.Errorf("Expected permanent errors not to open the breaker, got %v", err)
+	}
+	breaker.Record(errors.New("connection reset"))
+	err := breaker.Allow("ImportCertificate")
+	if _, ok := err.(*CircuitOpenError); !ok {
+		t.Fatalf("Expected the breaker to open, got %v", err)
+	}
+	if Classify(err) != ErrorClassThrottled || ErrorCode(err) != "CircuitOpen" {
+		t.Errorf("Unexpected class %s and code %s", Classify(err), ErrorCode(err))
+	}
+
+	breaker.openedAt = time.Now().Add(-2 * time.Hour)
+	if err := breaker.Allow("ImportCertificate"); err != nil {
+		t.Errorf("Expected a trial write after the cooldown, got %v", err)
+	}
+	if err := breaker.Allow("ImportCertificate"); err == nil {
+		t.Error("Expected a single trial write")
+	}
+	breaker.Record(nil)
+	if err := breaker.Allow("ImportCertificate"); err != nil {
+		t.Errorf("Expected the breaker to close after a successful write, got %v", err)
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits("ImportCertificate=0.5, DescribeCertificate=5:10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits["ImportCertificate"] != (Limit{Rate: 0.5, Burst: 1}) {
+		t.Errorf("Unexpected ImportCertificate limit %v", limits["ImportCertificate"])
+	}
+	if limits["DescribeCertificate"] != (Limit{Rate: 5, Burst: 10}) {
+		t.Errorf("Unexpected DescribeCertificate limit %v", limits["DescribeCertificate"])
+	}
+	if limits["ListCertificates"] != DefaultRateLimits["ListCertificates"] {
+		t.Errorf("Expected the default ListCertificates limit, got %v", limits["ListCertificates"])
+	}
+	if DefaultRateLimits["ImportCertificate"] != (Limit{Rate: 1, Burst: 1}) {
+		t.Error("Parsing modified the default limits")
+	}
+	if _, err := ParseRateLimits("ImportCertificate"); err == nil {
+		t.Error("Expected a limit without a rate to be refused")
+	}
+}
@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"go.uber.org/zap"
+)
+
+// DryRunService reads from ACM through Service but only logs the changes it would make
+type DryRunService struct {
+	Service IAcmService
+}
+
+// FormatTags formats tags as a comma separated list of key=value pairs
+func FormatTags(tags []*acm.Tag) string {
+	formatted := ""
+	for i, tag := range tags {
+		if i > 0 {
+			formatted += ","
+		}
+		formatted += aws.StringValue(tag.Key) + "=" + aws.StringValue(tag.Value)
+	}
+	return formatted
+}
+
+// UpsertCertificate returns the ARN that would be imported into, nil for a new certificate
+func (s *DryRunService) UpsertCertificate(input *acm.ImportCertificateInput) (*UpsertCertificateResponse, error) {
+	zap.S().Infow("Dry run: would import certificate",
+		"arn", aws.StringValue(input.CertificateArn),
+		"tags", FormatTags(input.Tags),
+	)
+	return &UpsertCertificateResponse{
+		CertificateArn: input.CertificateArn,
+		Tags:           input.Tags,
+	}, nil
+}
+
+func (s *DryRunService) DeleteCertificate(input *acm.DeleteCertificateInput) (*acm.DeleteCertificateOutput, error) {
+	zap.S().Infow("Dry run: would delete certificate", "arn", aws.StringValue(input.CertificateArn))
+	return &acm.DeleteCertificateOutput{}, nil
+}
+
+func (s *DryRunService) RemoveTagsFromCertificate(input *acm.RemoveTagsFromCertificateInput) (*acm.RemoveTagsFromCertificateOutput, error) {
+	zap.S().Infow("Dry run: would remove tags from certificate",
+		"arn", aws.StringValue(input.CertificateArn),
+		"tags", FormatTags(input.Tags),
+	)
+	return &acm.RemoveTagsFromCertificateOutput{}, nil
+}
+
+func (s *DryRunService) DescribeCertificate(input *acm.DescribeCertificateInput) (*acm.DescribeCertificateOutput, error) {
+	return s.Service.DescribeCertificate(input)
+}
+
+func (s *DryRunService) ListCertificates(input *acm.ListCertificatesInput) (*acm.ListCertificatesOutput, error) {
+	return s.Service.ListCertificates(input)
+}
+
+func (s *DryRunService) ListTagsForCertificate(input *acm.ListTagsForCertificateInput) (*acm.ListTagsForCertificateOutput, error) {
+	return s.Service.ListTagsForCertificate(input)
+}
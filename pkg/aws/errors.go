@@ -0,0 +1,64 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/acm"
+)
+
+// ErrorClass describes how an error returned by ACM should be retried
+type ErrorClass string
+
+const (
+	// ErrorClassRetryable errors are transient, such as network errors and AWS server errors
+	ErrorClassRetryable ErrorClass = "Retryable"
+	// ErrorClassThrottled errors mean requests to ACM are being rate limited
+	ErrorClassThrottled ErrorClass = "Throttled"
+	// ErrorClassQuota errors mean an ACM quota, such as the number of imports a year, has been reached
+	ErrorClassQuota ErrorClass = "Quota"
+	// ErrorClassPermanent errors fail the same way until the certificate, its tags or the controller's
+	// permissions are changed
+	ErrorClassPermanent ErrorClass = "Permanent"
+)
+
+// permanentCodes are the AWS error codes of requests that fail the same way when retried
+var permanentCodes = map[string]bool{
+	"AccessDenied":                       true,
+	"AccessDeniedException":              true,
+	"InvalidClientTokenId":               true,
+	"UnrecognizedClientException":        true,
+	"ValidationException":                true,
+	acm.ErrCodeInvalidArgsException:      true,
+	acm.ErrCodeInvalidArnException:       true,
+	acm.ErrCodeInvalidParameterException: true,
+	acm.ErrCodeInvalidTagException:       true,
+	acm.ErrCodeResourceInUseException:    true,
+	acm.ErrCodeResourceNotFoundException: true,
+	acm.ErrCodeTagPolicyException:        true,
+	acm.ErrCodeTooManyTagsException:      true,
+}
+
+// Classify returns the ErrorClass of an error returned by the SDK. Errors that are not recognised are
+// assumed to be retryable.
+func Classify(err error) ErrorClass {
+	if taggingErr, ok := err.(*TaggingError); ok {
+		err = taggingErr.Err
+	}
+	if _, ok := err.(*CircuitOpenError); ok {
+		return ErrorClassThrottled
+	}
+	if request.IsErrorThrottle(err) {
+		return ErrorClassThrottled
+	}
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return ErrorClassRetryable
+	}
+	if awsErr.Code() == acm.ErrCodeLimitExceededException {
+		return ErrorClassQuota
+	}
+	if permanentCodes[awsErr.Code()] {
+		return ErrorClassPermanent
+	}
+	return ErrorClassRetryable
+}
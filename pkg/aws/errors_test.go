@@ -0,0 +1,30 @@
+package aws
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{errors.New("connection reset"), ErrorClassRetryable},
+		{awserr.New("InternalFailure", "Internal failure", nil), ErrorClassRetryable},
+		{awserr.New("ThrottlingException", "Rate exceeded", nil), ErrorClassThrottled},
+		{&CircuitOpenError{Operation: "ImportCertificate"}, ErrorClassThrottled},
+		{awserr.New(acm.ErrCodeLimitExceededException, "Import limit reached", nil), ErrorClassQuota},
+		{awserr.New("ValidationException", "Invalid certificate chain", nil), ErrorClassPermanent},
+		{awserr.New(acm.ErrCodeResourceInUseException, "Certificate is in use", nil), ErrorClassPermanent},
+		{&TaggingError{Err: awserr.New(acm.ErrCodeTooManyTagsException, "Too many tags", nil)}, ErrorClassPermanent},
+	}
+
+	for _, c := range cases {
+		if class := Classify(c.err); class != c.class {
+			t.Errorf("Expected %v to be %s, got %s", c.err, c.class, class)
+		}
+	}
+}
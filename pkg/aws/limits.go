@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/time/rate"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is the rate, in calls per second, and burst of a token bucket
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits are the limits of each ACM API operation
+type RateLimits map[string]Limit
+
+// DefaultRateLimits are ACM's default per account and region quotas for the operations the controller
+// uses. Clusters sharing an account should split them between them.
+var DefaultRateLimits = RateLimits{
+	"AddTagsToCertificate":      {Rate: 5, Burst: 5},
+	"DeleteCertificate":         {Rate: 10, Burst: 10},
+	"DescribeCertificate":       {Rate: 10, Burst: 10},
+	"ImportCertificate":         {Rate: 1, Burst: 1},
+	"ListCertificates":          {Rate: 8, Burst: 8},
+	"ListTagsForCertificate":    {Rate: 10, Burst: 10},
+	"RemoveTagsFromCertificate": {Rate: 5, Burst: 5},
+}
+
+// ParseRateLimits parses a comma separated list of operation=rate or operation=rate:burst overrides of
+// DefaultRateLimits, as in "ImportCertificate=0.5,DescribeCertificate=5:10". The burst defaults to the
+// rate rounded up.
+func ParseRateLimits(value string) (RateLimits, error) {
+	limits := RateLimits{}
+	for operation, limit := range DefaultRateLimits {
+		limits[operation] = limit
+	}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("rate limit %q must be operation=rate[:burst]", field)
+		}
+		values := strings.SplitN(parts[1], ":", 2)
+		limitRate, err := strconv.ParseFloat(values[0], 64)
+		if err != nil || limitRate <= 0 {
+			return nil, fmt.Errorf("rate limit %q has an invalid rate", field)
+		}
+		burst := int(limitRate)
+		if float64(burst) < limitRate {
+			burst++
+		}
+		if len(values) == 2 {
+			if burst, err = strconv.Atoi(values[1]); err != nil || burst <= 0 {
+				return nil, fmt.Errorf("rate limit %q has an invalid burst", field)
+			}
+		}
+		limits[parts[0]] = Limit{Rate: limitRate, Burst: burst}
+	}
+	return limits, nil
+}
+
+// RateLimiter holds a token bucket per ACM API operation, so that all the reconcilers calling ACM
+// through the same service share its limits. Operations without a limit are not limited.
+type RateLimiter struct {
+	limits RateLimits
+
+	mutex    sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{limits: limits, limiters: map[string]*rate.Limiter{}}
+}
+
+// Wait blocks until a call to the operation is allowed
+func (l *RateLimiter) Wait(operation string) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	limiter, ok := l.limiters[operation]
+	if !ok {
+		if limit, limited := l.limits[operation]; limited {
+			limiter = rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst)
+		}
+		l.limiters[operation] = limiter
+	}
+	l.mutex.Unlock()
+	if limiter == nil {
+		return
+	}
+
+	start := time.Now()
+	_ = limiter.Wait(context.Background())
+	rateLimitWait.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
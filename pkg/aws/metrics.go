@@ -0,0 +1,44 @@
+package aws
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"time"
+)
+
+var (
+	apiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "acm_importer_acm_api_duration_seconds",
+		Help:    "Latency of ACM API calls",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	apiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acm_importer_acm_api_errors_total",
+		Help: "Number of failed ACM API calls by AWS error code",
+	}, []string{"operation", "code"})
+	rateLimitWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "acm_importer_acm_rate_limit_wait_seconds",
+		Help:    "Time ACM API calls waited for the rate limiter",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	circuitBreakerOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "acm_importer_acm_circuit_breaker_open",
+		Help: "Whether writes to ACM are paused after repeated failures, by service",
+	}, []string{"service"})
+	circuitBreakerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "acm_importer_acm_circuit_breaker_rejections_total",
+		Help: "Number of writes to ACM not made because the circuit breaker was open",
+	}, []string{"service", "operation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiDuration, apiErrors, rateLimitWait, circuitBreakerOpen, circuitBreakerRejections)
+}
+
+// observe records the latency and outcome of an ACM API call started at start
+func observe(operation string, start time.Time, err error) {
+	apiDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		apiErrors.WithLabelValues(operation, ErrorCode(err)).Inc()
+	}
+}